@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/rogpeppe/go-internal/lockedfile"
@@ -60,6 +61,42 @@ func GetKeyName(name string) string {
 	return filepath.Join(getCacheDir(), sha(name))
 }
 
+// GoEnviron returns the environment with the go build and
+// module caches set to directories inside the cache directory,
+// shared by all plugins. Cache locations already set in the
+// environment take precedence, and GOPROXY and GOFLAGS are
+// passed through. The -modcacherw flag is appended to GOFLAGS
+// so that the module cache can be removed with the rest of
+// the cache.
+func GoEnviron(env []string) []string {
+	defaults := map[string]string{
+		"GOCACHE":    filepath.Join(getCacheDir(), "go", "build"),
+		"GOMODCACHE": filepath.Join(getCacheDir(), "go", "mod"),
+	}
+	out := make([]string, 0, len(env)+len(defaults)+1)
+	goflags := ""
+	for _, v := range env {
+		key, value, _ := strings.Cut(v, "=")
+		if key == "GOFLAGS" {
+			goflags = value
+			continue
+		}
+		if _, ok := defaults[key]; ok && value != "" {
+			delete(defaults, key)
+		}
+		out = append(out, v)
+	}
+	for _, key := range []string{"GOCACHE", "GOMODCACHE"} {
+		if dir, ok := defaults[key]; ok {
+			out = append(out, key+"="+dir)
+		}
+	}
+	if !strings.Contains(goflags, "-modcacherw") {
+		goflags = strings.TrimSpace(goflags + " -modcacherw")
+	}
+	return append(out, "GOFLAGS="+goflags)
+}
+
 func getCacheDir() string {
 	dir, _ := os.UserHomeDir()
 	return filepath.Join(dir, ".cache")
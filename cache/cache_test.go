@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoEnviron(t *testing.T) {
+	dir := getCacheDir()
+	tests := []struct {
+		name     string
+		env      []string
+		expected []string
+	}{
+		{
+			name: "defaults",
+			env:  []string{"HOME=/root"},
+			expected: []string{
+				"HOME=/root",
+				"GOCACHE=" + filepath.Join(dir, "go", "build"),
+				"GOMODCACHE=" + filepath.Join(dir, "go", "mod"),
+				"GOFLAGS=-modcacherw",
+			},
+		},
+		{
+			name: "overrides",
+			env:  []string{"GOMODCACHE=/go/pkg/mod", "GOPROXY=https://proxy.example.com", "GOFLAGS=-mod=mod"},
+			expected: []string{
+				"GOMODCACHE=/go/pkg/mod",
+				"GOPROXY=https://proxy.example.com",
+				"GOCACHE=" + filepath.Join(dir, "go", "build"),
+				"GOFLAGS=-mod=mod -modcacherw",
+			},
+		},
+		{
+			name: "modcacherw",
+			env:  []string{"GOCACHE=/tmp/go-build", "GOFLAGS=-modcacherw"},
+			expected: []string{
+				"GOCACHE=/tmp/go-build",
+				"GOMODCACHE=" + filepath.Join(dir, "go", "mod"),
+				"GOFLAGS=-modcacherw",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, GoEnviron(tt.env))
+		})
+	}
+}
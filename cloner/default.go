@@ -142,3 +142,17 @@ func matchRefNotFoundErr(err error) bool {
 	regex := regexp.MustCompile(pattern)
 	return regex.MatchString(err.Error())
 }
+
+// Head returns the commit sha of the repository head in the
+// directory.
+func Head(dir string) (string, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
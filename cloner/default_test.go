@@ -8,6 +8,10 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -53,3 +57,23 @@ func testDir(t *testing.T) string {
 	t.Cleanup(func() { _ = os.RemoveAll(basedir) })
 	return basedir
 }
+
+func TestHead(t *testing.T) {
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	hash, err := w.Commit("initial commit", &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "octocat", Email: "octocat@github.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	got, err := Head(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, hash.String(), got)
+
+	_, err = Head(t.TempDir())
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/drone/plugin/cloner"
+	"github.com/drone/plugin/lockfile"
+	"github.com/drone/plugin/plugin/bitrise"
+	"github.com/drone/plugin/plugin/github"
+	"github.com/drone/plugin/plugin/harness"
+	"github.com/drone/plugin/utils"
+)
+
+// lock resolves the plugin references and writes the resolved
+// repository, commit and binary digests to the lockfile. The
+// plugins already in the lockfile are kept, and updated if
+// resolved again, for example:
+//
+//	plugin lock -kind action actions/setup-java@v3
+//	plugin lock -kind harness slack@^3 webhook
+//	plugin lock -kind binary https://example.com/plugin-linux-amd64
+func lock(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("lock", flag.ExitOnError)
+	kind := flags.String("kind", "", "plugin kind (action, bitrise, harness, binary)")
+	path := flags.String("lockfile", "plugin.lock", "lockfile path")
+	index := flags.String("index", "", "additional harness plugin index file (path or url)")
+	var steplibs utils.CustomStringSliceFlag
+	flags.Var(&steplibs, "steplib", "bitrise step library repository urls or local directories, in order of priority (empty to use the builtin index)")
+	steplibTTL := flags.Duration("steplib-ttl", 24*time.Hour, "bitrise step library refresh interval")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return errors.New("no plugin references provided")
+	}
+
+	locked, err := lockfile.Read(*path)
+	if os.IsNotExist(err) {
+		locked = new(lockfile.Lockfile)
+	} else if err != nil {
+		return err
+	}
+
+	if *kind == "harness" && *index != "" {
+		if err := harness.LoadIndex(ctx, *index); err != nil {
+			return err
+		}
+	}
+
+	if *kind == "bitrise" && usesSteplib(flags.Args()) {
+		if err := bitrise.Sync(ctx, steplibSources(steplibs, flags.Args()...), *steplibTTL); err != nil {
+			slog.Warn("cannot sync steplib", "error", err)
+		}
+	}
+
+	for _, name := range flags.Args() {
+		plugin, err := resolve(ctx, *kind, name)
+		if err != nil {
+			return fmt.Errorf("cannot lock plugin %s: %w", name, err)
+		}
+		slog.Info("locked plugin",
+			slog.String("name", name),
+			slog.String("kind", plugin.Kind),
+			slog.String("repo", plugin.Repo),
+			slog.String("commit", plugin.Commit),
+			slog.Int("binaries", len(plugin.Binaries)))
+		locked.Add(plugin)
+	}
+	return lockfile.Write(*path, locked)
+}
+
+// helper function resolves the plugin reference to the
+// repository commit and binary digests.
+func resolve(ctx context.Context, kind, name string) (*lockfile.Plugin, error) {
+	plugin := &lockfile.Plugin{Name: name, Kind: kind}
+	switch kind {
+	case "binary":
+		digest, err := harness.Digest(name)
+		if err != nil {
+			return nil, err
+		}
+		plugin.Binaries = append(plugin.Binaries, &lockfile.Binary{Source: name, Digest: digest})
+		return plugin, nil
+	case "action":
+		plugin.Repo, plugin.Ref, _ = github.ParseLookup(name)
+	case "harness":
+		plugin.Repo, plugin.Ref, plugin.Commit, _ = harness.ParseLookup(name)
+	case "bitrise":
+		plugin.Repo, plugin.Commit, _ = bitrise.ParseLookup(name)
+	default:
+		return nil, fmt.Errorf("unsupported plugin kind: %q", kind)
+	}
+	if plugin.Repo == "" {
+		return nil, errors.New("cannot resolve plugin repository")
+	}
+
+	// clone the plugin into a temporary directory, bypassing
+	// the clone cache, to resolve the ref to the current
+	// commit.
+	dir, err := os.MkdirTemp("", "plugin-lock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	params := cloner.Params{Repo: plugin.Repo, Ref: plugin.Ref, Sha: plugin.Commit, Dir: dir}
+	if err := cloner.New(1, io.Discard).Clone(ctx, params); err != nil {
+		return nil, err
+	}
+	if plugin.Commit, err = cloner.Head(dir); err != nil {
+		return nil, err
+	}
+
+	// the binary sources of harness plugins are locked for
+	// the host platform and each declared platform. sources
+	// that cannot be downloaded are skipped, since they may
+	// be fallback sources.
+	if kind == "harness" && harness.Is(dir) {
+		sources, err := harness.BinarySources(dir, plugin.Ref, plugin.Commit, os.Environ())
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range sources {
+			digest, err := harness.Digest(source)
+			if err != nil {
+				slog.Warn("cannot lock binary source", slog.String("source", source), "error", err)
+				continue
+			}
+			plugin.Binaries = append(plugin.Binaries, &lockfile.Binary{Source: source, Digest: digest})
+		}
+	}
+	return plugin, nil
+}
+
+// helper function returns true if any of the bitrise steps
+// are resolved using the step library index.
+func usesSteplib(steps []string) bool {
+	for _, step := range steps {
+		if bitrise.UsesSteplib(step) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lockfile provides support for pinning resolved
+// plugin references to a repository commit and binary
+// digests.
+package lockfile
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// Lockfile defines the resolved plugin references.
+	Lockfile struct {
+		Plugins []*Plugin `yaml:"plugins"`
+	}
+
+	// Plugin defines a resolved plugin reference.
+	Plugin struct {
+		Name     string    `yaml:"name"`
+		Kind     string    `yaml:"kind,omitempty"`
+		Repo     string    `yaml:"repo,omitempty"`
+		Ref      string    `yaml:"ref,omitempty"`
+		Commit   string    `yaml:"commit,omitempty"`
+		Binaries []*Binary `yaml:"binaries,omitempty"`
+	}
+
+	// Binary defines a resolved binary source.
+	Binary struct {
+		Source string `yaml:"source"`
+		Digest string `yaml:"digest"`
+	}
+)
+
+// Read reads the lockfile from the path.
+func Read(path string) (*Lockfile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := new(Lockfile)
+	if err := yaml.Unmarshal(raw, out); err != nil {
+		return nil, fmt.Errorf("cannot parse lockfile %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// Write writes the lockfile to the path, with the plugins
+// sorted by kind and name.
+func Write(path string, lock *Lockfile) error {
+	sort.SliceStable(lock.Plugins, func(i, j int) bool {
+		a, b := lock.Plugins[i], lock.Plugins[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	raw, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0644)
+}
+
+// Find returns the locked plugin with the name. If the kind
+// is empty, the first plugin with the name is returned.
+func (l *Lockfile) Find(kind, name string) *Plugin {
+	for _, plugin := range l.Plugins {
+		if plugin.Name == name && (kind == "" || plugin.Kind == kind) {
+			return plugin
+		}
+	}
+	return nil
+}
+
+// Add adds the plugin to the lockfile, replacing the locked
+// plugin with the same kind and name.
+func (l *Lockfile) Add(plugin *Plugin) {
+	for i, locked := range l.Plugins {
+		if locked.Name == plugin.Name && locked.Kind == plugin.Kind {
+			l.Plugins[i] = plugin
+			return
+		}
+	}
+	l.Plugins = append(l.Plugins, plugin)
+}
+
+// Checksums returns the binary digests of every locked
+// plugin, keyed by binary source.
+func (l *Lockfile) Checksums() map[string]string {
+	out := map[string]string{}
+	for _, plugin := range l.Plugins {
+		for _, binary := range plugin.Binaries {
+			out[binary.Source] = binary.Digest
+		}
+	}
+	return out
+}
+
+// Verify returns an error if the repository or commit differs
+// from the locked repository and commit. An empty commit is
+// not verified, since it is resolved when the plugin is cloned.
+func (p *Plugin) Verify(repo, commit string) error {
+	if p.Repo != repo {
+		return fmt.Errorf("plugin %s repository %s does not match locked repository %s", p.Name, repo, p.Repo)
+	}
+	if commit != "" && p.Commit != commit {
+		return fmt.Errorf("plugin %s commit %s does not match locked commit %s", p.Name, commit, p.Commit)
+	}
+	return nil
+}
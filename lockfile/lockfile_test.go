@@ -0,0 +1,108 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lockfile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugin.lock")
+	lock := &Lockfile{
+		Plugins: []*Plugin{
+			{
+				Name:   "webhook",
+				Kind:   "harness",
+				Repo:   "https://github.com/drone-plugins/drone-webhook.git",
+				Commit: "b83c0042154f9c5d4bc3c42a847c3c287c12a505",
+			},
+			{
+				Name:   "actions/setup-java@v3",
+				Kind:   "action",
+				Repo:   "https://github.com/actions/setup-java",
+				Ref:    "v3",
+				Commit: "0ab4596768b603586c0de567f2430c30f5b0d2b0",
+			},
+			{
+				Name: "https://example.com/plugin",
+				Kind: "binary",
+				Binaries: []*Binary{
+					{Source: "https://example.com/plugin", Digest: "sha256:abc"},
+				},
+			},
+		},
+	}
+	if err := Write(path, lock); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, got.Plugins, 3)
+
+	// plugins are sorted by kind and name.
+	assert.Equal(t, "action", got.Plugins[0].Kind)
+	assert.Equal(t, "binary", got.Plugins[1].Kind)
+	assert.Equal(t, "harness", got.Plugins[2].Kind)
+	assert.Equal(t, lock.Plugins[0], got.Plugins[0])
+}
+
+func TestRead_NotExist(t *testing.T) {
+	_, err := Read(filepath.Join(t.TempDir(), "plugin.lock"))
+	assert.Error(t, err)
+}
+
+func TestFind(t *testing.T) {
+	lock := &Lockfile{
+		Plugins: []*Plugin{
+			{Name: "slack", Kind: "harness", Repo: "harness"},
+			{Name: "slack", Kind: "bitrise", Repo: "bitrise"},
+		},
+	}
+	assert.Equal(t, "bitrise", lock.Find("bitrise", "slack").Repo)
+	assert.Equal(t, "harness", lock.Find("", "slack").Repo)
+	assert.Nil(t, lock.Find("action", "slack"))
+	assert.Nil(t, lock.Find("harness", "webhook"))
+}
+
+func TestAdd(t *testing.T) {
+	lock := new(Lockfile)
+	lock.Add(&Plugin{Name: "slack", Kind: "harness", Commit: "a"})
+	lock.Add(&Plugin{Name: "slack", Kind: "bitrise", Commit: "b"})
+	lock.Add(&Plugin{Name: "slack", Kind: "harness", Commit: "c"})
+	assert.Len(t, lock.Plugins, 2)
+	assert.Equal(t, "c", lock.Find("harness", "slack").Commit)
+}
+
+func TestChecksums(t *testing.T) {
+	lock := &Lockfile{
+		Plugins: []*Plugin{
+			{Name: "a", Binaries: []*Binary{{Source: "https://example.com/a", Digest: "sha256:a"}}},
+			{Name: "b", Binaries: []*Binary{{Source: "https://example.com/b", Digest: "sha256:b"}}},
+			{Name: "c"},
+		},
+	}
+	assert.Equal(t, map[string]string{
+		"https://example.com/a": "sha256:a",
+		"https://example.com/b": "sha256:b",
+	}, lock.Checksums())
+	assert.NotNil(t, new(Lockfile).Checksums())
+}
+
+func TestVerify(t *testing.T) {
+	plugin := &Plugin{
+		Name:   "webhook",
+		Repo:   "https://github.com/drone-plugins/drone-webhook.git",
+		Commit: "b83c0042154f9c5d4bc3c42a847c3c287c12a505",
+	}
+	assert.NoError(t, plugin.Verify(plugin.Repo, plugin.Commit))
+	assert.NoError(t, plugin.Verify(plugin.Repo, ""))
+	assert.Error(t, plugin.Verify(plugin.Repo, "0000000000000000000000000000000000000000"))
+	assert.Error(t, plugin.Verify("https://github.com/octocat/drone-webhook.git", plugin.Commit))
+}
@@ -6,13 +6,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"golang.org/x/exp/slog"
 
 	"github.com/drone/plugin/cloner"
+	"github.com/drone/plugin/lockfile"
 	"github.com/drone/plugin/plugin/bitrise"
 	"github.com/drone/plugin/plugin/github"
 	"github.com/drone/plugin/plugin/harness"
@@ -29,10 +35,32 @@ var (
 	downloadOnly  bool                        // plugin won't be executed on setting this flag. Only source will be downloaded. Used for caching the plugin dependencies
 	disableClone  bool                        // plugin does not clone when this flag is enabled
 	binarySources utils.CustomStringSliceFlag // plugin uses these binary source urls in the same order to download the binaires
+	strictDeps    bool                        // plugin fails if a dependency fails to install
+	timeout       time.Duration               // plugin step timeout
+	depsTimeout   time.Duration               // plugin dependency installation timeout
+	buildTimeout  time.Duration               // plugin build timeout
+	runTimeout    time.Duration               // plugin execution timeout
+	gracePeriod   time.Duration               // plugin grace period after it is signaled
+	indexFile     string                      // additional harness plugin index file (path or url)
+	lockFile      string                      // plugin lockfile, plugins that differ from the lockfile are not executed
+	steplibs      utils.CustomStringSliceFlag // bitrise step library repository urls or local directories, in order of priority
+	steplibTTL    time.Duration               // bitrise step library refresh interval
 	showVersion   bool                        // show version and exit
 )
 
 func main() {
+	// the plugin executes envman when invoked as envman, by
+	// the envman shim on the bitrise step path, or with the
+	// hidden envman subcommand.
+	if bitrise.IsEnvman(os.Args[0]) {
+		envman(os.Args[1:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "envman" {
+		envman(os.Args[2:])
+		return
+	}
+
 	// Handle special commands before flag parsing
 	if len(os.Args) > 1 && os.Args[1] != "" {
 		if os.Args[1] == "healthz" {
@@ -44,7 +72,11 @@ func main() {
 			return
 		}
 	}
-	ctx := context.Background()
+	// trap termination signals so that they can be forwarded
+	// to the plugin, giving the plugin an opportunity to exit
+	// gracefully and the runner to flush outputs and cleanup.
+	ctx, stop := notifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	level := slog.LevelInfo
 	if os.Getenv("DRONE_DEBUG") == "true" {
@@ -53,6 +85,15 @@ func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
 	slog.SetDefault(logger)
 
+	// resolve the plugin references and write the lockfile
+	if len(os.Args) > 1 && os.Args[1] == "lock" {
+		if err := lock(ctx, os.Args[2:]); err != nil {
+			slog.Error("cannot write lockfile", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// parse the input parameters
 	flag.StringVar(&name, "name", "", "plugin name")
 	flag.StringVar(&repo, "repo", "", "plugin repository")
@@ -62,6 +103,16 @@ func main() {
 	flag.BoolVar(&downloadOnly, "download-only", false, "plugin downloadOnly")
 	flag.BoolVar(&disableClone, "disable-clone", false, "disable clone functionality")
 	flag.Var(&binarySources, "sources", "source urls to download binaries")
+	flag.BoolVar(&strictDeps, "strict-deps", false, "fail if a dependency fails to install")
+	flag.DurationVar(&timeout, "timeout", 0, "plugin step timeout")
+	flag.DurationVar(&depsTimeout, "deps-timeout", 0, "plugin dependency installation timeout")
+	flag.DurationVar(&buildTimeout, "build-timeout", 0, "plugin build timeout")
+	flag.DurationVar(&runTimeout, "run-timeout", 0, "plugin execution timeout")
+	flag.DurationVar(&gracePeriod, "grace-period", 10*time.Second, "time to wait for the plugin to exit after it is signaled")
+	flag.StringVar(&indexFile, "index", "", "additional harness plugin index file (path or url)")
+	flag.StringVar(&lockFile, "lockfile", "", "refuse to run plugins that differ from the lockfile")
+	flag.Var(&steplibs, "steplib", "bitrise step library repository urls or local directories, in order of priority (empty to use the builtin index)")
+	flag.DurationVar(&steplibTTL, "steplib-ttl", 24*time.Hour, "bitrise step library refresh interval")
 	flag.Parse()
 
 	// the user may specific the action plugin alias instead
@@ -79,6 +130,12 @@ func main() {
 	// of the git repository. We are able to lookup the plugin
 	// by alias to find the corresponding repository and commit.
 	if repo == "" && kind == "harness" {
+		if indexFile != "" {
+			if err := harness.LoadIndex(ctx, indexFile); err != nil {
+				slog.Error("cannot load plugin index", "error", err)
+				os.Exit(1)
+			}
+		}
 		repo_, ref_, sha_, ok := harness.ParseLookup(name)
 		if ok {
 			repo = repo_
@@ -91,6 +148,13 @@ func main() {
 	// of the git repository. We are able to lookup the plugin
 	// by alias to find the corresponding repository and commit.
 	if repo == "" && kind == "bitrise" {
+		// the step library is only synced if the step is
+		// resolved using the step library index.
+		if bitrise.UsesSteplib(name) {
+			if err := bitrise.Sync(ctx, steplibSources(steplibs, name), steplibTTL); err != nil {
+				slog.Warn("cannot sync steplib", "error", err)
+			}
+		}
 		repo_, sha_, ok := bitrise.ParseLookup(name)
 		if ok {
 			repo = repo_
@@ -105,6 +169,38 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the plugin must match the lockfile, if provided. the
+	// commit is verified again once the plugin is cloned,
+	// since the ref may resolve to a different commit.
+	var locked *lockfile.Lockfile
+	var pinned *lockfile.Plugin
+	if lockFile != "" {
+		locked, err = lockfile.Read(lockFile)
+		if err != nil {
+			slog.Error("cannot read lockfile", "error", err)
+			os.Exit(1)
+		}
+		if !disableClone {
+			key := name
+			if key == "" {
+				key = repo
+			}
+			pinned = locked.Find(kind, key)
+			if pinned == nil {
+				slog.Error("plugin not found in lockfile", slog.String("name", key))
+				os.Exit(1)
+			}
+			if err := pinned.Verify(repo, sha); err != nil {
+				slog.Error("plugin does not match lockfile", "error", err)
+				os.Exit(1)
+			}
+		} else {
+			// the plugin repository is not cloned, so only
+			// the binary checksums are verified.
+			slog.Warn("clone is disabled, plugin repository is not verified against the lockfile")
+		}
+	}
+
 	// clone the plugin repository
 	var codedir string
 	if !disableClone {
@@ -114,9 +210,27 @@ func main() {
 			slog.Error("cannot clone the plugin", "error", err)
 			os.Exit(1)
 		}
+		if pinned != nil {
+			commit, err := cloner.Head(codedir)
+			if err == nil {
+				err = pinned.Verify(repo, commit)
+			}
+			if err != nil {
+				slog.Error("plugin does not match lockfile", "error", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// binary checksums are enforced if a lockfile is
+	// provided.
+	var checksums map[string]string
+	if locked != nil {
+		checksums = locked.Checksums()
 	}
 
 	outputFile := os.Getenv("DRONE_OUTPUT")
+	secretOutputFile := os.Getenv("HARNESS_OUTPUT_SECRET_FILE")
 
 	switch {
 	// execute harness plugin
@@ -130,16 +244,28 @@ func main() {
 			Source:        codedir,
 			Workdir:       workdir,
 			Ref:           ref,
+			Sha:           sha,
 			Environ:       os.Environ(),
 			Stdout:        os.Stdout,
 			Stderr:        os.Stderr,
 			BinarySources: binarySources,
 			DisableClone:  disableClone,
 			DownloadOnly:  downloadOnly,
+			StrictDeps:    strictDeps,
+
+			Timeout:      timeout,
+			DepsTimeout:  depsTimeout,
+			BuildTimeout: buildTimeout,
+			RunTimeout:   runTimeout,
+			GracePeriod:  gracePeriod,
+
+			OutputFile:       outputFile,
+			SecretOutputFile: secretOutputFile,
+
+			Checksums: checksums,
 		}
 		if err := execer.Exec(ctx); err != nil {
-			slog.Error("step failed", "error", err)
-			os.Exit(1)
+			exit(err)
 		}
 
 	// execute bitrise plugin
@@ -153,11 +279,17 @@ func main() {
 			Environ: bitrise.Environ(
 				os.Environ(),
 			),
-			OutputFile: outputFile,
+			OutputFile:       outputFile,
+			SecretOutputFile: secretOutputFile,
+
+			Timeout:      timeout,
+			DepsTimeout:  depsTimeout,
+			BuildTimeout: buildTimeout,
+			RunTimeout:   runTimeout,
+			GracePeriod:  gracePeriod,
 		}
 		if err := execer.Exec(ctx); err != nil {
-			slog.Error("step failed", "error", err)
-			os.Exit(1)
+			exit(err)
 		}
 
 	case github.Is(codedir) || kind == "action":
@@ -179,3 +311,81 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// helper function executes envman and exits on error. The
+// stdin is provided to envman only if it is a pipe.
+func envman(args []string) {
+	var stdin io.Reader
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		stdin = os.Stdin
+	}
+	if err := bitrise.Envman(args, stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// helper function logs the step error and exits. Timeouts
+// and cancellations are reported separately from other step
+// failures.
+func exit(err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Error("step timed out", "error", err)
+	} else if errors.Is(err, context.Canceled) {
+		slog.Error("step cancelled", "error", err)
+	} else {
+		slog.Error("step failed", "error", err)
+	}
+	os.Exit(1)
+}
+
+// helper function returns the step libraries provided by
+// flag, or the public step library if none are provided,
+// followed by the step libraries referenced by the steps
+// (steplib-url::step-id@version).
+func steplibSources(flags utils.CustomStringSliceFlag, steps ...string) []string {
+	urls := flags.Value
+	if urls == nil {
+		urls = []string{bitrise.DefaultSteplib}
+	}
+	for _, step := range steps {
+		if url := bitrise.Steplib(step); url != "" {
+			urls = append(urls[:len(urls):len(urls)], url)
+		}
+	}
+	return urls
+}
+
+// signalError is the context cancellation cause when the
+// process receives a termination signal. The signal is
+// forwarded to the running plugin process.
+type signalError struct {
+	sig os.Signal
+}
+
+func (e *signalError) Error() string     { return "received signal: " + e.sig.String() }
+func (e *signalError) Signal() os.Signal { return e.sig }
+
+// helper function returns a context that is cancelled when
+// the process receives one of the listed signals, with the
+// signal provided as the cancellation cause. The default
+// signal behavior is restored after the first signal, so
+// that a second signal terminates the process immediately.
+func notifyContext(parent context.Context, signals ...os.Signal) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		select {
+		case sig := <-ch:
+			slog.Info("received signal, stopping plugin", slog.String("signal", sig.String()))
+			signal.Stop(ch)
+			cancel(&signalError{sig: sig})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(ch)
+		cancel(nil)
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"os"
+	"runtime"
 	"testing"
+	"time"
 
+	"github.com/drone/plugin/plugin/bitrise"
 	"github.com/drone/plugin/utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -171,3 +176,51 @@ func TestFlagParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestNotifyContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix signals required")
+	}
+
+	ctx, stop := notifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	proc, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proc.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Want context cancelled when signal is received")
+	}
+
+	cause := new(signalError)
+	if !errors.As(context.Cause(ctx), &cause) {
+		t.Fatalf("Want signal cancellation cause, got %v", context.Cause(ctx))
+	}
+	assert.Equal(t, os.Interrupt, cause.Signal())
+}
+
+func TestSteplibSources(t *testing.T) {
+	private := "https://github.com/octocat/bitrise-steplib.git"
+
+	// the public step library is used by default.
+	assert.Equal(t, []string{bitrise.DefaultSteplib},
+		steplibSources(utils.CustomStringSliceFlag{}, "hello@1.0.0"))
+
+	// the step libraries provided by flag replace the public
+	// step library.
+	var flags utils.CustomStringSliceFlag
+	flags.Set(private + ";" + bitrise.DefaultSteplib)
+	assert.Equal(t, []string{private, bitrise.DefaultSteplib},
+		steplibSources(flags, "hello@1.0.0"))
+
+	// the step libraries referenced by the steps are appended.
+	assert.Equal(t, []string{bitrise.DefaultSteplib, private},
+		steplibSources(utils.CustomStringSliceFlag{}, private+"::hello@1.0.0"))
+}
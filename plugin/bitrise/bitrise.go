@@ -6,9 +6,6 @@
 package bitrise
 
 import (
-	"github.com/joho/godotenv"
-	"gopkg.in/yaml.v3"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 )
@@ -26,39 +23,3 @@ func Is(root string) bool {
 	}
 	return false
 }
-
-func exists(base, file string) bool {
-	path := filepath.Join(base, file)
-	if _, err := os.Stat(path); err == nil {
-		return true
-	}
-	return false
-}
-
-func readEnvStore(root string) (*envStore, error) {
-	buf, err := ioutil.ReadFile(filepath.Join(root, envStoreFile))
-	if err != nil {
-		return nil, err
-	}
-
-	m := &envStore{}
-	err = yaml.Unmarshal(buf, m)
-	if err != nil {
-		return nil, err
-	}
-
-	return m, err
-}
-
-func saveOutputFromEnvStore(envs []map[string]string, outputfile string) error {
-	finalMap := make(map[string]string)
-	for _, env := range envs {
-		for k, v := range env {
-			finalMap[k] = v
-		}
-	}
-	if len(finalMap) > 0 {
-		return godotenv.Write(finalMap, outputfile)
-	}
-	return nil
-}
@@ -0,0 +1,254 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// environment variable that provides the envstore path to
+// envman.
+const envStorePathEnv = "ENVMAN_ENVSTORE_PATH"
+
+// Envman executes the envman command. It is an envman
+// compatible implementation of the init, add, print and clear
+// commands, used by bitrise steps to export outputs, for
+// example:
+//
+//	envman add --key BITRISE_IPA_PATH --value /deploy/app.ipa
+//	echo "$CHANGELOG" | envman add --key BITRISE_CHANGELOG
+//
+// The stdin is read by the add command if a value is not
+// provided. It should be nil if stdin is not a pipe.
+func Envman(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("envman", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	path := flags.String("path", os.Getenv(envStorePathEnv), "envstore path")
+	flags.StringVar(path, "p", os.Getenv(envStorePathEnv), "envstore path")
+	flags.String("loglevel", "", "log level")
+	flags.String("l", "", "log level")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		*path = envStoreFile
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New("envman: command required")
+	}
+	switch command, args := args[0], args[1:]; command {
+	case "init":
+		return envmanInit(*path, args)
+	case "add":
+		return envmanAdd(*path, args, stdin)
+	case "print":
+		return envmanPrint(*path, args, stdout)
+	case "clear":
+		return createEnvStore(*path)
+	default:
+		return fmt.Errorf("envman: unsupported command: %s", command)
+	}
+}
+
+// helper function executes the envman init command, which
+// creates the envstore if it does not exist.
+func envmanInit(path string, args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	clear := flags.Bool("clear", false, "clear the envstore")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !*clear {
+		return nil
+	}
+	return createEnvStore(path)
+}
+
+// helper function executes the envman add command.
+func envmanAdd(path string, args []string, stdin io.Reader) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	key := flags.String("key", "", "environment variable key")
+	value := flags.String("value", "", "environment variable value")
+	valueFile := flags.String("valuefile", "", "environment variable value file")
+	append_ := flags.Bool("append", false, "append to existing environment variables with the same key")
+	noExpand := flags.Bool("no-expand", false, "disable expansion")
+	sensitive := flags.Bool("sensitive", false, "sensitive value")
+	skipIfEmpty := flags.Bool("skip-if-empty", false, "skip if the value is empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *key == "" {
+		return errors.New("envman: key required")
+	}
+
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// the value is provided by flag, file or piped to stdin,
+	// in order of precedence.
+	item := &envItem{key: *key, value: *value}
+	switch {
+	case set["value"]:
+	case *valueFile != "":
+		raw, err := os.ReadFile(*valueFile)
+		if err != nil {
+			return err
+		}
+		item.value = string(raw)
+	case stdin != nil:
+		raw, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		item.value = string(raw)
+	}
+	if *noExpand {
+		expand := false
+		item.opts.IsExpand = &expand
+	}
+	item.opts.IsSensitive = *sensitive
+	item.opts.SkipIfEmpty = *skipIfEmpty
+
+	return updateEnvStore(path, func(store *envStore) error {
+		store.add(item, *append_)
+		return nil
+	})
+}
+
+// helper function executes the envman print command.
+func envmanPrint(path string, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("print", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "raw", "output format (raw, json)")
+	expand := flags.Bool("expand", false, "expand the environment variables")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := readEnvStore(path)
+	if err != nil {
+		return err
+	}
+	values := map[string]string{}
+	var keys []string
+	for _, item := range store.items() {
+		if _, ok := values[item.key]; !ok {
+			keys = append(keys, item.key)
+		}
+		value := item.value
+		if *expand && (item.opts.IsExpand == nil || *item.opts.IsExpand) {
+			value = os.Expand(value, func(s string) string {
+				if v, ok := values[s]; ok {
+					return v
+				}
+				return os.Getenv(s)
+			})
+		}
+		values[item.key] = value
+	}
+
+	switch *format {
+	case "raw":
+		for _, key := range keys {
+			fmt.Fprintf(stdout, "%s: %s\n", key, values[key])
+		}
+		return nil
+	case "json":
+		return json.NewEncoder(stdout).Encode(values)
+	default:
+		return fmt.Errorf("envman: unsupported format: %s", *format)
+	}
+}
+
+// setupEnvman creates an empty envstore and an envman shim
+// that invokes the current executable, so that steps can
+// export outputs without the envman binary installed. The
+// shim is placed first on the step path, and the envstore
+// path is provided to envman in the environment.
+func (e *Execer) setupEnvman(env []string) ([]string, func(), error) {
+	dir, err := os.MkdirTemp("", "envman")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	e.envstorePath = filepath.Join(dir, envStoreFile)
+	if err := createEnvStore(e.envstorePath); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	env = append(env[:len(env):len(env)], envStorePathEnv+"="+e.envstorePath)
+
+	executable := e.Envman
+	if executable == "" {
+		executable, err = os.Executable()
+	}
+	if err == nil {
+		bin := filepath.Join(dir, "bin")
+		err = writeEnvmanShim(bin, executable)
+		if err == nil {
+			env = prependPath(env, bin)
+		}
+	}
+	if err != nil {
+		// the step falls back to the envman binary, if
+		// installed, which also writes to the envstore.
+		slog.Warn("cannot create envman shim", "error", err)
+	}
+	return env, cleanup, nil
+}
+
+// helper function writes the envman shim to the directory. The
+// shim is a symlink to the executable, which executes envman
+// when invoked as envman, or a script that invokes the hidden
+// envman subcommand on windows.
+func writeEnvmanShim(dir, executable string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		script := fmt.Sprintf("@\"%s\" envman %%*\r\n", executable)
+		return os.WriteFile(filepath.Join(dir, "envman.cmd"), []byte(script), 0700)
+	}
+	return os.Symlink(executable, filepath.Join(dir, "envman"))
+}
+
+// helper function prepends the directory to the path in the
+// environment.
+func prependPath(env []string, dir string) []string {
+	out := make([]string, 0, len(env)+1)
+	found := false
+	for _, v := range env {
+		key, value, _ := strings.Cut(v, "=")
+		if strings.EqualFold(key, "PATH") && !found {
+			found = true
+			v = key + "=" + dir + string(os.PathListSeparator) + value
+		}
+		out = append(out, v)
+	}
+	if !found {
+		out = append(out, "PATH="+dir)
+	}
+	return out
+}
+
+// IsEnvman returns true if the executable is invoked as envman.
+func IsEnvman(arg0 string) bool {
+	return strings.TrimSuffix(filepath.Base(arg0), ".exe") == "envman"
+}
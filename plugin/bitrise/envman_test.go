@@ -0,0 +1,179 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+)
+
+// the test binary executes envman when invoked by the envman
+// shim, so that steps can be tested end to end.
+func TestMain(m *testing.M) {
+	if IsEnvman(os.Args[0]) {
+		if err := Envman(os.Args[1:], nil, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestEnvman(t *testing.T) {
+	path := filepath.Join(t.TempDir(), envStoreFile)
+	envman := func(stdin string, args ...string) string {
+		t.Helper()
+		var in io.Reader
+		if stdin != "" {
+			in = strings.NewReader(stdin)
+		}
+		out := new(bytes.Buffer)
+		if err := Envman(append([]string{"--path", path}, args...), in, out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	envman("", "init")
+	envman("", "add", "--key", "A", "--value", "first")
+	envman("", "add", "--key", "A", "--value", "second")
+	envman("", "add", "--key", "B", "--value", "$A/b", "--no-expand")
+	envman("", "add", "--key", "C", "--value", "$A/c")
+	envman("line1\nline2", "add", "--key", "D", "--sensitive")
+	envman("", "add", "--key", "E", "--value", "", "--skip-if-empty")
+
+	valueFile := filepath.Join(t.TempDir(), "value")
+	if err := os.WriteFile(valueFile, []byte("from file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	envman("", "add", "--key", "F", "--valuefile", valueFile)
+
+	// init does not clear an existing envstore.
+	envman("", "init")
+
+	assert.Equal(t, "A: second\nB: $A/b\nC: $A/c\nD: line1\nline2\nE: \nF: from file\n", envman("", "print"))
+	assert.Equal(t, `{"A":"second","B":"$A/b","C":"second/c","D":"line1\nline2","E":"","F":"from file"}`+"\n",
+		envman("", "print", "--format", "json", "--expand"))
+
+	store, err := readEnvStore(path)
+	assert.NoError(t, err)
+	values, sensitive := store.values()
+	assert.Equal(t, map[string]string{
+		"A": "second",
+		"B": "$A/b",
+		"C": "$A/c",
+		"D": "line1\nline2",
+		"F": "from file",
+	}, values)
+	assert.True(t, sensitive["D"])
+	assert.False(t, sensitive["A"])
+
+	envman("", "clear")
+	assert.Equal(t, "", envman("", "print"))
+}
+
+func TestEnvman_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), envStoreFile)
+	t.Setenv(envStorePathEnv, path)
+
+	assert.NoError(t, Envman([]string{"add", "--key", "A", "--value", "1"}, nil, nil))
+	assert.NoError(t, Envman([]string{"add", "--key", "A", "--value", "2", "--append"}, nil, nil))
+
+	store, err := readEnvStore(path)
+	assert.NoError(t, err)
+	assert.Len(t, store.items(), 2)
+	values, _ := store.values()
+	assert.Equal(t, "2", values["A"])
+}
+
+func TestEnvman_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), envStoreFile)
+	assert.Error(t, Envman([]string{"--path", path}, nil, nil))
+	assert.Error(t, Envman([]string{"--path", path, "run", "env"}, nil, nil))
+	assert.Error(t, Envman([]string{"--path", path, "add", "--value", "foo"}, nil, nil))
+	assert.Error(t, Envman([]string{"--path", path, "print"}, nil, nil))
+}
+
+func TestIsEnvman(t *testing.T) {
+	assert.True(t, IsEnvman("envman"))
+	assert.True(t, IsEnvman("/tmp/envman/bin/envman"))
+	assert.True(t, IsEnvman("/tmp/envman/bin/envman.exe"))
+	assert.False(t, IsEnvman("/usr/local/bin/plugin"))
+}
+
+func TestPrependPath(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	assert.Equal(t, []string{"HOME=/root", "PATH=/shim" + sep + "/usr/bin"},
+		prependPath([]string{"HOME=/root", "PATH=/usr/bin"}, "/shim"))
+	assert.Equal(t, []string{"HOME=/root", "PATH=/shim"},
+		prependPath([]string{"HOME=/root"}, "/shim"))
+}
+
+func TestExec_Envman(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash required")
+	}
+
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "step.yml"), []byte(`
+toolkit:
+  bash:
+    entry_file: step.sh
+outputs:
+- BITRISE_IPA_PATH:
+- BITRISE_CHANGELOG:
+- BITRISE_API_TOKEN:
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "step.sh"), []byte(`
+set -e
+envman add --key BITRISE_IPA_PATH --value /deploy/app.ipa
+envman add --key BITRISE_CHANGELOG --value "$(printf 'first\nsecond')"
+envman add --key BITRISE_API_TOKEN --value secret --sensitive
+envman add --key UNDECLARED --value ignored
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	e := &Execer{
+		Source:           source,
+		Workdir:          dir,
+		Environ:          os.Environ(),
+		Stdout:           os.Stdout,
+		Stderr:           os.Stderr,
+		OutputFile:       filepath.Join(dir, "output.env"),
+		SecretOutputFile: filepath.Join(dir, "secret.env"),
+	}
+	if err := e.Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := godotenv.Read(e.OutputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"BITRISE_IPA_PATH":  "/deploy/app.ipa",
+		"BITRISE_CHANGELOG": "first\nsecond",
+	}, plain)
+
+	secret, err := godotenv.Read(e.SecretOutputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"BITRISE_API_TOKEN": "secret"}, secret)
+
+	// the envstore is removed once the step completes.
+	assert.NoFileExists(t, e.envstorePath)
+}
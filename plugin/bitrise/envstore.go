@@ -1,6 +1,148 @@
 package bitrise
 
-// envStore ...
+import (
+	"fmt"
+	"os"
+
+	"github.com/rogpeppe/go-internal/lockedfile"
+	"gopkg.in/yaml.v3"
+)
+
+// envStore defines the envman environment store, where each
+// item is a map with the environment variable key and value,
+// and the environment variable options.
 type envStore struct {
-	Envs []map[string]string `json:"envs" yaml:"envs"`
+	Envs []map[string]interface{} `json:"envs" yaml:"envs"`
+}
+
+// envOpts defines the envman environment variable options.
+type envOpts struct {
+	IsExpand    *bool
+	IsSensitive bool
+	SkipIfEmpty bool
+}
+
+// envItem defines an envman environment variable.
+type envItem struct {
+	key   string
+	value string
+	opts  envOpts
+}
+
+// items returns the environment variables in the store.
+func (s *envStore) items() []*envItem {
+	var out []*envItem
+	for _, env := range s.Envs {
+		item := new(envItem)
+		for k, v := range env {
+			if k == "opts" {
+				if opts, ok := v.(map[string]interface{}); ok {
+					if isExpand, ok := opts["is_expand"].(bool); ok {
+						item.opts.IsExpand = &isExpand
+					}
+					item.opts.IsSensitive, _ = opts["is_sensitive"].(bool)
+					item.opts.SkipIfEmpty, _ = opts["skip_if_empty"].(bool)
+				}
+				continue
+			}
+			item.key = k
+			if v != nil {
+				item.value = fmt.Sprint(v)
+			}
+		}
+		if item.key != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// add adds the environment variable to the store. Unless
+// appended, existing environment variables with the same key
+// are removed.
+func (s *envStore) add(item *envItem, append_ bool) {
+	if !append_ {
+		var envs []map[string]interface{}
+		for _, env := range s.Envs {
+			if _, ok := env[item.key]; !ok {
+				envs = append(envs, env)
+			}
+		}
+		s.Envs = envs
+	}
+
+	env := map[string]interface{}{item.key: item.value}
+	opts := map[string]interface{}{}
+	if item.opts.IsExpand != nil && !*item.opts.IsExpand {
+		opts["is_expand"] = false
+	}
+	if item.opts.IsSensitive {
+		opts["is_sensitive"] = true
+	}
+	if item.opts.SkipIfEmpty {
+		opts["skip_if_empty"] = true
+	}
+	if len(opts) > 0 {
+		env["opts"] = opts
+	}
+	s.Envs = append(s.Envs, env)
+}
+
+// values returns the environment variable values, and the
+// keys of the environment variables marked as sensitive.
+// If a key is set more than once, the last value is used.
+// Empty values marked skip if empty are ignored.
+func (s *envStore) values() (map[string]string, map[string]bool) {
+	values := map[string]string{}
+	sensitive := map[string]bool{}
+	for _, item := range s.items() {
+		if item.value == "" && item.opts.SkipIfEmpty {
+			continue
+		}
+		values[item.key] = item.value
+		sensitive[item.key] = item.opts.IsSensitive
+	}
+	return values, sensitive
+}
+
+// readEnvStore reads the envstore file.
+func readEnvStore(path string) (*envStore, error) {
+	buf, err := lockedfile.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseEnvStore(buf)
+}
+
+// updateEnvStore reads the envstore file, creating the file
+// if it does not exist, and writes the updated envstore.
+func updateEnvStore(path string, fn func(*envStore) error) error {
+	return lockedfile.Transform(path, func(buf []byte) ([]byte, error) {
+		store, err := parseEnvStore(buf)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(store); err != nil {
+			return nil, err
+		}
+		if len(store.Envs) == 0 {
+			return []byte("envs: []\n"), nil
+		}
+		return yaml.Marshal(store)
+	})
+}
+
+// helper function parses the envstore file.
+func parseEnvStore(buf []byte) (*envStore, error) {
+	m := &envStore{}
+	if err := yaml.Unmarshal(buf, m); err != nil {
+		return nil, fmt.Errorf("invalid envstore file: %w", err)
+	}
+	return m, nil
+}
+
+// helper function creates the envstore file. An existing
+// envstore file is cleared.
+func createEnvStore(path string) error {
+	return os.WriteFile(path, []byte("envs: []\n"), 0600)
 }
@@ -6,14 +6,15 @@ package bitrise
 
 import (
 	"context"
-	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"time"
 
+	"github.com/drone/plugin/cache"
 	"github.com/drone/plugin/plugin/internal/environ"
+	"github.com/drone/plugin/plugin/internal/process"
 	"golang.org/x/exp/slog"
 )
 
@@ -25,35 +26,71 @@ type Execer struct {
 	Stdout     io.Writer
 	Stderr     io.Writer
 	OutputFile string
+
+	SecretOutputFile string // step secret output file
+
+	// Envman provides the executable invoked by the envman
+	// shim. It defaults to the current executable, which
+	// must execute envman when invoked as envman.
+	Envman string
+
+	envstorePath string // step envstore file
+
+	Timeout      time.Duration // overall step timeout
+	DepsTimeout  time.Duration // dependency installation timeout
+	BuildTimeout time.Duration // step build timeout
+	RunTimeout   time.Duration // step execution timeout
+	GracePeriod  time.Duration // time to wait for the step to exit after it is signaled
 }
 
 // Exec executes a bitrise plugin.
 func (e *Execer) Exec(ctx context.Context) error {
+	if e.GracePeriod > 0 {
+		ctx = process.WithGracePeriod(ctx, e.GracePeriod)
+	}
+	ctx, done := process.WithTimeout(ctx, "step", e.Timeout)
+	return done(e.execStep(ctx))
+}
+
+func (e *Execer) execStep(ctx context.Context) error {
 	// parse the bitrise plugin yaml
 	out, err := parseFile(filepath.Join(e.Source, "step.yml"))
 	if err != nil {
 		return err
 	}
 
+	// resolve the step inputs before installing dependencies,
+	// so that the step fails fast on invalid inputs.
+	stepEnv, secrets, err := e.getStepEnv(out)
+	if err != nil {
+		return err
+	}
+
+	// sensitive input values are masked in the step output.
+	stdout := newRedactor(e.Stdout, secrets)
+	stderr := newRedactor(e.Stderr, secrets)
+
 	// install linux dependencies
+	depsCtx, depsDone := process.WithTimeout(ctx, "deps", e.DepsTimeout)
 	if runtime.GOOS == "linux" {
-		e.installAptDeps(out)
+		e.installAptDeps(depsCtx, out)
 	}
 
 	// install darwin dependencies
 	if runtime.GOOS == "darwin" {
-		e.installBrewDeps(out)
+		e.installBrewDeps(depsCtx, out)
+	}
+	if err := depsDone(depsCtx.Err()); err != nil {
+		return err
 	}
 
-	// create the .envstore.yml file if not present
-	if !exists(e.Source, envStoreFile) {
-		slog.Debug("envman init")
-		cmd := exec.Command("envman", "init")
-		cmd.Dir = e.Source
-		if err := cmd.Run(); err != nil {
-			slog.Warn("Unable to create envstore file", err)
-		}
+	// create the envstore and the envman shim, which the
+	// step uses to export outputs.
+	stepEnv, cleanup, err := e.setupEnvman(stepEnv)
+	if err != nil {
+		return err
 	}
+	defer cleanup()
 
 	module := out.Toolkit.Go.Module
 	if module == "" {
@@ -61,61 +98,62 @@ func (e *Execer) Exec(ctx context.Context) error {
 	}
 	// execute the plugin. the execution logic differs
 	// based on programming language.
-	stepEnv := e.getStepEnv(out)
 	if module != "" {
 		// if the plugin is a Go module
-		if err := e.runGoModule(module, stepEnv); err != nil {
-			return err
-		}
+		err = e.runGoModule(ctx, module, stepEnv, stdout, stderr)
 	} else {
 		// else if the plugin is a Bash script
-		if err := e.runBashScript(out, stepEnv); err != nil {
-			return err
-		}
+		err = e.runBashScript(ctx, out, stepEnv, stdout, stderr)
 	}
 
-	// save to outputfile if present
-	if len(e.OutputFile) > 0 {
-		if m, err := readEnvStore(e.Source); err == nil && len(m.Envs) > 0 {
-			if err = saveOutputFromEnvStore(m.Envs, e.OutputFile); err != nil {
-				slog.Error("Unable to save output", err)
-			}
-		} else if err != nil {
-			slog.Error("Unable to load envstore file", err)
+	// outputs are saved if the step succeeds, or if the
+	// step is terminated, in which case the outputs written
+	// before termination are flushed.
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	if serr := e.saveOutputs(out.Outputs); serr != nil {
+		if err != nil {
+			slog.Error("failed to save outputs", "error", serr)
+		} else {
+			err = serr
 		}
 	}
-
-	return nil
+	return err
 }
 
-func (e *Execer) runGoModule(module string, env []string) error {
+func (e *Execer) runGoModule(ctx context.Context, module string, env []string,
+	stdout, stderr io.Writer) error {
 	slog.Debug("go build", slog.String("module", module))
 	// compile the code
 	binpath := filepath.Join(e.Source, "step.exe")
-	cmd := exec.Command("go", "build", "-o", binpath, module)
-	cmd.Env = env
+	buildCtx, buildDone := process.WithTimeout(ctx, "build", e.BuildTimeout)
+	cmd := process.Command(buildCtx, "go", "build", "-o", binpath, module)
+	cmd.Env = cache.GoEnviron(env)
 	cmd.Dir = e.Source
-	cmd.Stderr = e.Stderr
-	cmd.Stdout = e.Stdout
-	if err := cmd.Run(); err != nil {
+	cmd.Stderr = stderr
+	cmd.Stdout = stdout
+	if err := buildDone(cmd.Run()); err != nil {
 		return err
 	}
 
 	slog.Debug("go run", slog.String("module", module))
 
 	// execute the binary
-	cmd = exec.Command(binpath)
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
+	cmd = process.Command(ctx, binpath)
 	cmd.Env = env
 	cmd.Dir = e.Workdir
-	cmd.Stderr = e.Stderr
-	cmd.Stdout = e.Stdout
-	if err := cmd.Run(); err != nil {
+	cmd.Stderr = stderr
+	cmd.Stdout = stdout
+	if err := done(cmd.Run()); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (e *Execer) runBashScript(out *spec, env []string) error {
+func (e *Execer) runBashScript(ctx context.Context, out *spec, env []string,
+	stdout, stderr io.Writer) error {
 	// determine the default script path
 	script := out.Toolkit.Bash.Entryfile
 	path := filepath.Join(e.Source, script)
@@ -130,22 +168,23 @@ func (e *Execer) runBashScript(out *spec, env []string) error {
 	}
 
 	// execute the binary
-	cmd := exec.Command(shell, path)
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
+	cmd := process.Command(ctx, shell, path)
 	cmd.Env = env
 	cmd.Dir = e.Workdir
-	cmd.Stderr = e.Stderr
-	cmd.Stdout = e.Stdout
-	if err := cmd.Run(); err != nil {
+	cmd.Stderr = stderr
+	cmd.Stdout = stdout
+	if err := done(cmd.Run()); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (e *Execer) installBrewDeps(out *spec) {
+func (e *Execer) installBrewDeps(ctx context.Context, out *spec) {
 	for _, item := range out.Deps.Brew {
 		slog.Debug("brew install", slog.String("package", item.Name))
 
-		cmd := exec.Command("brew", "install", item.Name)
+		cmd := process.Command(ctx, "brew", "install", item.Name)
 		cmd.Env = e.Environ
 		cmd.Dir = e.Workdir
 		cmd.Stderr = e.Stderr
@@ -154,11 +193,11 @@ func (e *Execer) installBrewDeps(out *spec) {
 	}
 }
 
-func (e *Execer) installAptDeps(out *spec) {
+func (e *Execer) installAptDeps(ctx context.Context, out *spec) {
 	if len(out.Deps.Aptget) > 0 {
 		slog.Debug("apt-get update")
 
-		cmd := exec.Command("sudo", "apt-get", "update")
+		cmd := process.Command(ctx, "sudo", "apt-get", "update")
 		cmd.Env = e.Environ
 		cmd.Dir = e.Workdir
 		cmd.Stderr = e.Stderr
@@ -169,7 +208,7 @@ func (e *Execer) installAptDeps(out *spec) {
 	for _, item := range out.Deps.Aptget {
 		slog.Debug("apt-get install", slog.String("package", item.Name))
 
-		cmd := exec.Command("sudo", "apt-get", "install", item.Name)
+		cmd := process.Command(ctx, "sudo", "apt-get", "install", item.Name)
 		cmd.Env = e.Environ
 		cmd.Stderr = e.Stderr
 		cmd.Stdout = e.Stdout
@@ -177,35 +216,16 @@ func (e *Execer) installAptDeps(out *spec) {
 	}
 }
 
-func (e *Execer) getStepEnv(out *spec) []string {
-	defaults := e.getDefaults(out.Inputs)
-	env := environ.Map(e.Environ)
-	for k, v := range defaults {
-		if _, ok := env[k]; !ok {
-			env[k] = v
-		}
+// getStepEnv returns the step environment with the step
+// inputs applied, and the values of the sensitive inputs.
+func (e *Execer) getStepEnv(out *spec) ([]string, []string, error) {
+	inputs, err := parseInputs(out.Inputs)
+	if err != nil {
+		return nil, nil, err
 	}
-	return environ.Slice(env)
-}
-
-func (e *Execer) getDefaults(inputs []map[string]interface{}) map[string]string {
-	o := make(map[string]string)
-
-	env := environ.Map(e.Environ)
-	for _, in := range inputs {
-		for k, v := range in {
-			if k != "opts" {
-				default_ := ""
-				if v == nil {
-					default_ = "null"
-				} else {
-					default_ = fmt.Sprintf("%v", v)
-				}
-				if default_ != "" {
-					o[k] = os.Expand(default_, func(s string) string { return env[s] })
-				}
-			}
-		}
+	env, err := resolveInputs(inputs, environ.Map(e.Environ))
+	if err != nil {
+		return nil, nil, err
 	}
-	return o
+	return environ.Slice(env), sensitiveValues(inputs, env), nil
 }
@@ -3,3 +3,56 @@
 // license that can be found in the LICENSE file.
 
 package bitrise
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_Sensitive(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash required")
+	}
+
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "step.yml"), []byte(`
+toolkit:
+  bash:
+    entry_file: step.sh
+inputs:
+- username:
+- password:
+  opts:
+    is_sensitive: true
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "step.sh"), []byte(`
+echo "username $username"
+echo "password $password"
+echo "password $password" >&2
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	e := &Execer{
+		Source:  source,
+		Workdir: t.TempDir(),
+		Environ: append(os.Environ(), "username=octocat", "password=hunter2"),
+		Stdout:  stdout,
+		Stderr:  stderr,
+	}
+	if err := e.Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "username octocat\npassword ******\n", stdout.String())
+	assert.Equal(t, "password ******\n", stderr.String())
+}
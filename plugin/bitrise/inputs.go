@@ -0,0 +1,150 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v2"
+)
+
+// input defines a step input. The step.yml input is a map
+// with the input key and default value, and the input
+// options, for example:
+//
+//	inputs:
+//	- api_token:
+//	  opts:
+//	    is_required: true
+//	    is_sensitive: true
+type input struct {
+	key   string
+	value string
+	opts  inputOpts
+}
+
+// inputOpts defines the step input options.
+type inputOpts struct {
+	IsRequired        bool          `yaml:"is_required"`
+	IsExpand          *bool         `yaml:"is_expand"`
+	IsDontChangeValue bool          `yaml:"is_dont_change_value"`
+	IsSensitive       bool          `yaml:"is_sensitive"`
+	ValueOptions      []interface{} `yaml:"value_options"`
+}
+
+// parseInputs parses the step.yml inputs.
+func parseInputs(inputs []map[string]interface{}) ([]*input, error) {
+	var out []*input
+	for _, in := range inputs {
+		item := new(input)
+		for k, v := range in {
+			if k == "opts" {
+				raw, err := yaml.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				if err := yaml.Unmarshal(raw, &item.opts); err != nil {
+					return nil, fmt.Errorf("invalid input options: %w", err)
+				}
+				continue
+			}
+			item.key = k
+			if v != nil {
+				item.value = fmt.Sprint(v)
+			}
+		}
+		if item.key != "" {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// resolveInputs returns the environment with the step input
+// values applied. Input values provided by the environment
+// take precedence over the default values, unless the input
+// value cannot be changed. Default values are expanded using
+// the environment, unless expansion is disabled. An error is
+// returned if a required input is empty, or if a value is not
+// one of the input value options.
+func resolveInputs(inputs []*input, env map[string]string) (map[string]string, error) {
+	out := map[string]string{}
+	for k, v := range env {
+		out[k] = v
+	}
+
+	var missing []string
+	for _, in := range inputs {
+		val, ok := env[in.key]
+		if ok && in.opts.IsDontChangeValue && val != in.value {
+			slog.Warn("input value cannot be changed, using default value", slog.String("name", in.key))
+			ok = false
+		}
+		if !ok {
+			val = in.value
+			if in.opts.IsExpand == nil || *in.opts.IsExpand {
+				val = os.Expand(val, func(s string) string { return env[s] })
+			}
+		}
+
+		if val == "" {
+			if in.opts.IsRequired {
+				missing = append(missing, in.key)
+			}
+			continue
+		}
+		if err := in.validate(val); err != nil {
+			return nil, err
+		}
+		out[in.key] = val
+
+		if in.opts.IsSensitive {
+			slog.Debug("input", slog.String("name", in.key), slog.String("value", mask))
+		} else {
+			slog.Debug("input", slog.String("name", in.key), slog.String("value", val))
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required inputs: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// sensitiveValues returns the resolved values of the inputs
+// marked as sensitive.
+func sensitiveValues(inputs []*input, env map[string]string) []string {
+	var out []string
+	for _, in := range inputs {
+		if val := env[in.key]; in.opts.IsSensitive && val != "" {
+			out = append(out, val)
+		}
+	}
+	return out
+}
+
+// validate returns an error if the value is not one of the
+// input value options.
+func (in *input) validate(val string) error {
+	if len(in.opts.ValueOptions) == 0 {
+		return nil
+	}
+	var options []string
+	for _, option := range in.opts.ValueOptions {
+		if fmt.Sprint(option) == val {
+			return nil
+		}
+		options = append(options, fmt.Sprint(option))
+	}
+	if in.opts.IsSensitive {
+		return fmt.Errorf("input %s: value must be one of [%s]", in.key, strings.Join(options, ", "))
+	}
+	return fmt.Errorf("input %s: value %q must be one of [%s]", in.key, val, strings.Join(options, ", "))
+}
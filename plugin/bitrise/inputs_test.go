@@ -0,0 +1,156 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+// helper function parses the step.yml inputs.
+func mustParseInputs(t *testing.T, s string) []*input {
+	out := new(spec)
+	if err := yaml.Unmarshal([]byte(s), out); err != nil {
+		t.Fatal(err)
+	}
+	inputs, err := parseInputs(out.Inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inputs
+}
+
+func TestParseInputs(t *testing.T) {
+	inputs := mustParseInputs(t, `
+inputs:
+- api_token:
+  opts:
+    is_required: true
+    is_sensitive: true
+- export_method: app-store
+  opts:
+    value_options:
+    - app-store
+    - ad-hoc
+- retries: 3
+  opts:
+    is_expand: false
+    is_dont_change_value: true
+`)
+	assert.Len(t, inputs, 3)
+
+	assert.Equal(t, "api_token", inputs[0].key)
+	assert.Equal(t, "", inputs[0].value)
+	assert.True(t, inputs[0].opts.IsRequired)
+	assert.True(t, inputs[0].opts.IsSensitive)
+
+	assert.Equal(t, "export_method", inputs[1].key)
+	assert.Equal(t, "app-store", inputs[1].value)
+	assert.Equal(t, []interface{}{"app-store", "ad-hoc"}, inputs[1].opts.ValueOptions)
+	assert.Nil(t, inputs[1].opts.IsExpand)
+
+	assert.Equal(t, "retries", inputs[2].key)
+	assert.Equal(t, "3", inputs[2].value)
+	assert.False(t, *inputs[2].opts.IsExpand)
+	assert.True(t, inputs[2].opts.IsDontChangeValue)
+}
+
+func TestResolveInputs(t *testing.T) {
+	inputs := mustParseInputs(t, `
+inputs:
+- api_token:
+  opts:
+    is_required: true
+    is_sensitive: true
+- export_method: app-store
+  opts:
+    value_options:
+    - app-store
+    - ad-hoc
+- output_dir: $BITRISE_DEPLOY_DIR/ipa
+- pattern: $HOME/*.ipa
+  opts:
+    is_expand: false
+- verbose: "false"
+  opts:
+    is_dont_change_value: true
+- optional:
+`)
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		want   map[string]string
+		errMsg string
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{"api_token": "secret", "BITRISE_DEPLOY_DIR": "/deploy", "HOME": "/root"},
+			want: map[string]string{
+				"api_token":          "secret",
+				"export_method":      "app-store",
+				"output_dir":         "/deploy/ipa",
+				"pattern":            "$HOME/*.ipa",
+				"verbose":            "false",
+				"BITRISE_DEPLOY_DIR": "/deploy",
+				"HOME":               "/root",
+			},
+		},
+		{
+			name: "overrides",
+			env:  map[string]string{"api_token": "secret", "export_method": "ad-hoc", "verbose": "true", "optional": "yes"},
+			want: map[string]string{
+				"api_token":     "secret",
+				"export_method": "ad-hoc",
+				"output_dir":    "/ipa",
+				"pattern":       "$HOME/*.ipa",
+				"verbose":       "false",
+				"optional":      "yes",
+			},
+		},
+		{
+			name:   "required",
+			env:    map[string]string{},
+			errMsg: "missing required inputs: api_token",
+		},
+		{
+			name:   "required empty",
+			env:    map[string]string{"api_token": ""},
+			errMsg: "missing required inputs: api_token",
+		},
+		{
+			name:   "value options",
+			env:    map[string]string{"api_token": "secret", "export_method": "enterprise"},
+			errMsg: `input export_method: value "enterprise" must be one of [app-store, ad-hoc]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolveInputs(inputs, test.env)
+			if test.errMsg != "" {
+				assert.EqualError(t, err, test.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestResolveInputs_Sensitive(t *testing.T) {
+	inputs := mustParseInputs(t, `
+inputs:
+- password:
+  opts:
+    is_sensitive: true
+    value_options:
+    - hunter2
+`)
+	_, err := resolveInputs(inputs, map[string]string{"password": "letmein"})
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "letmein")
+}
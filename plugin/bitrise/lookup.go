@@ -4,36 +4,95 @@
 
 package bitrise
 
-import "strings"
+import (
+	"strings"
 
+	"github.com/drone/plugin/plugin/internal/versions"
+	"golang.org/x/exp/slog"
+)
+
+// the builtin step index is generated from the public step
+// library, and is used as an offline fallback if the step
+// library cannot be synced at runtime.
 //go:generate go run ../../scripts/bitrise.go
 
 // Lookup returns the repository and commit associated
-// with the named step and version.
+// with the named step and version. The step is found in
+// the step libraries in order of priority.
 func Lookup(name, version string) (repo string, commit string, ok bool) {
-	// find the named step
-	plugin_, ok := index[name]
-	if !ok {
+	for _, lib := range sources() {
+		if plugin_, ok := lib.index[name]; ok {
+			// the step library with the highest priority
+			// that provides the step is authoritative, and
+			// lower priority step libraries are not searched
+			// for the version, so that a private step cannot
+			// be shadowed by a public step with the same id.
+			return lookupRelease(lib, plugin_, version)
+		}
+	}
+	return
+}
+
+// LookupSteplib returns the repository and commit associated
+// with the named step and version in the step library.
+func LookupSteplib(steplib, name, version string) (repo string, commit string, ok bool) {
+	for _, lib := range sources() {
+		if lib.url != normalizeSteplib(steplib) {
+			continue
+		}
+		if plugin_, ok := lib.index[name]; ok {
+			return lookupRelease(lib, plugin_, version)
+		}
 		return
 	}
+	slog.Warn("steplib not synced", slog.String("steplib", steplib))
+	return
+}
+
+// helper function returns the release of the step that
+// matches the version.
+func lookupRelease(lib *steplib, plugin_ plugin, version string) (repo string, commit string, ok bool) {
 	// use default version if none provided
 	if version == "" {
 		version = plugin_.version
 	}
-	release_, ok := plugin_.releases[version]
+
+	// resolve the version range (e.g. ^3, ~3.1, 3.x or
+	// latest) to the latest matching release.
+	var available []string
+	for v := range plugin_.releases {
+		available = append(available, v)
+	}
+	resolved, ok := versions.Resolve(version, available)
 	if !ok {
 		return
 	}
+	release_ := plugin_.releases[resolved]
+	slog.Info("resolved step version",
+		slog.String("name", plugin_.name),
+		slog.String("steplib", lib.url),
+		slog.String("version", version),
+		slog.String("resolved", resolved),
+		slog.String("commit", release_.commit))
 	return release_.repo, release_.commit, ok
 }
 
 // ParseLookup parses the step string and returns the
 // associated repository and commit.
 func ParseLookup(s string) (repo string, commit string, ok bool) {
+	// if the string is prefixed with a step library url
+	// (steplib-url::step-id@version) the step is found in
+	// the step library.
+	if steplib, step, ok := splitSteplib(s); ok {
+		if parts := strings.SplitN(step, "@", 2); len(parts) == 2 {
+			return LookupSteplib(steplib, parts[0], parts[1])
+		}
+		return LookupSteplib(steplib, step, "")
+	}
+
 	// if the strings is prefixed with git:: it means the
 	// repository url was provided directly.
-	if strings.HasPrefix(s, "git:") || strings.HasPrefix(s, "github.com") ||
-		strings.HasPrefix(s, "https://github.com") {
+	if isRepository(s) {
 		// trim the git:: prefix
 		s = strings.TrimPrefix(s, "git::")
 
@@ -61,6 +120,46 @@ func ParseLookup(s string) (repo string, commit string, ok bool) {
 	return Lookup(s, "")
 }
 
+// UsesSteplib returns true if the step string is resolved
+// using a step library index. This is the case for a step id
+// (step-id@version) or a step prefixed with a step library
+// url (steplib-url::step-id@version), but not for a git
+// repository url.
+func UsesSteplib(s string) bool {
+	if _, _, ok := splitSteplib(s); ok {
+		return true
+	}
+	return !isRepository(s)
+}
+
+// helper function returns true if the step string is a git
+// repository url.
+func isRepository(s string) bool {
+	return strings.HasPrefix(s, "git:") || strings.HasPrefix(s, "github.com") ||
+		strings.HasPrefix(s, "https://github.com")
+}
+
+// Steplib returns the step library url of the step string,
+// if the step string is prefixed with a step library url
+// (steplib-url::step-id@version).
+func Steplib(s string) string {
+	steplib, _, _ := splitSteplib(s)
+	return steplib
+}
+
+// helper function splits the step string into the step
+// library url and the step.
+func splitSteplib(s string) (steplib, step string, ok bool) {
+	if strings.HasPrefix(s, "git::") {
+		return "", "", false
+	}
+	i := strings.LastIndex(s, "::")
+	if i <= 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+2:], true
+}
+
 type plugin struct {
 	name     string
 	version  string
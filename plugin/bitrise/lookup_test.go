@@ -32,6 +32,33 @@ func TestLookup_Default(t *testing.T) {
 	}
 }
 
+func TestLookup_Range(t *testing.T) {
+	tests := []struct {
+		version string
+		commit  string
+	}{
+		{version: "3.x", commit: "ba111960d4ef6616a00c162195129e0134e410e6"},
+		{version: "3", commit: "ba111960d4ef6616a00c162195129e0134e410e6"},
+		{version: "^3", commit: "ba111960d4ef6616a00c162195129e0134e410e6"},
+		{version: "~3.0", commit: "5c42e6fe8642d8416faac742b0e137053f9ac9f3"},
+		{version: "4.0", commit: "17c9d33e3da625774824f1ab1942569b59fcd6dd"},
+		{version: "latest", commit: "9f0fc00b7a2483a283c0d82106d6816638ac7d41"},
+	}
+	for _, test := range tests {
+		_, commit, ok := Lookup("activate-ssh-key", test.version)
+		if !ok {
+			t.Errorf("Expect found step version %s", test.version)
+		}
+		if got, want := commit, test.commit; got != want {
+			t.Errorf("Expect commit %s for version %s, got %s", want, test.version, got)
+		}
+	}
+
+	if _, _, ok := Lookup("activate-ssh-key", "^5"); ok {
+		t.Errorf("Expect step version ^5 not found")
+	}
+}
+
 func TestParseLookup(t *testing.T) {
 	tests := []struct {
 		name string
@@ -48,6 +75,11 @@ func TestParseLookup(t *testing.T) {
 			repo: "https://github.com/bitrise-io/steps-activate-ssh-key.git",
 			hash: "d4d437de5d7de7cdb4e25116c12fd0344a03923e",
 		},
+		{
+			name: "activate-ssh-key@3.x",
+			repo: "https://github.com/bitrise-io/steps-activate-ssh-key.git",
+			hash: "ba111960d4ef6616a00c162195129e0134e410e6",
+		},
 		{
 			name: "git::https://github.com/ocotcat/hello-world.git",
 			repo: "https://github.com/ocotcat/hello-world.git",
@@ -74,3 +106,24 @@ func TestParseLookup(t *testing.T) {
 		}
 	}
 }
+
+func TestUsesSteplib(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"activate-ssh-key", true},
+		{"activate-ssh-key@3.x", true},
+		{"https://github.com/acme/steplib.git::deploy@1.0.0", true},
+		{"/opt/steplib::deploy", true},
+		{"git::https://github.com/ocotcat/hello-world.git", false},
+		{"git::https://github.com/ocotcat/hello-world.git@v1", false},
+		{"github.com/ocotcat/hello-world", false},
+		{"https://github.com/ocotcat/hello-world.git", false},
+	}
+	for _, test := range tests {
+		if got := UsesSteplib(test.name); got != test.want {
+			t.Errorf("Want UsesSteplib(%q) %v, got %v", test.name, test.want, got)
+		}
+	}
+}
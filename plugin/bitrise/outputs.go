@@ -0,0 +1,73 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"os"
+
+	"github.com/drone/plugin/plugin/internal/environ"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slog"
+)
+
+// saveOutputs reads the envstore and writes the outputs
+// declared in the step.yml to the step output file. Sensitive
+// outputs are written to the secret output file.
+func (e *Execer) saveOutputs(declared []map[string]interface{}) error {
+	outputs, err := parseInputs(declared)
+	if err != nil {
+		return err
+	}
+	if len(outputs) == 0 || e.envstorePath == "" || (e.OutputFile == "" && e.SecretOutputFile == "") {
+		return nil
+	}
+
+	store, err := readEnvStore(e.envstorePath)
+	if os.IsNotExist(err) {
+		slog.Debug("envstore file not found, skipping outputs")
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to load envstore file")
+	}
+	values, sensitive := store.values()
+
+	plain := map[string]string{}
+	secret := map[string]string{}
+	for _, out := range outputs {
+		val, ok := values[out.key]
+		if !ok {
+			slog.Debug("output not set", slog.String("name", out.key))
+			continue
+		}
+		delete(values, out.key)
+		if out.opts.IsSensitive || sensitive[out.key] {
+			secret[out.key] = val
+		} else {
+			plain[out.key] = val
+		}
+	}
+
+	// environment variables that are not declared as
+	// outputs in the step.yml are not exported.
+	for name := range values {
+		slog.Debug("ignoring undeclared output", slog.String("name", name))
+	}
+
+	if len(plain) > 0 {
+		if e.OutputFile == "" {
+			slog.Warn("output file not set, skipping outputs")
+		} else if err := environ.WriteFile(e.OutputFile, plain); err != nil {
+			return errors.Wrap(err, "failed to write outputs")
+		}
+	}
+	if len(secret) > 0 {
+		if e.SecretOutputFile == "" {
+			slog.Warn("secret output file not set, skipping secret outputs")
+		} else if err := environ.WriteFile(e.SecretOutputFile, secret); err != nil {
+			return errors.Wrap(err, "failed to write secret outputs")
+		}
+	}
+	return nil
+}
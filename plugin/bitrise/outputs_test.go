@@ -0,0 +1,85 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSaveOutputs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, envStoreFile), []byte(`
+envs:
+- BITRISE_IPA_PATH: /deploy/app.ipa
+- BITRISE_CHANGELOG: |-
+    first line
+    second "quoted" line
+- BITRISE_BUILD_NUMBER: "007"
+- BITRISE_API_TOKEN: secret
+- BITRISE_SIGNING_KEY: key
+  opts:
+    is_sensitive: true
+- UNRELATED: value
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(spec)
+	yaml.Unmarshal([]byte(`
+outputs:
+- BITRISE_IPA_PATH:
+  opts:
+    title: ipa path
+- BITRISE_CHANGELOG:
+- BITRISE_BUILD_NUMBER:
+- BITRISE_API_TOKEN:
+  opts:
+    is_sensitive: true
+- BITRISE_SIGNING_KEY:
+- BITRISE_NOT_SET:
+`), out)
+
+	e := &Execer{
+		envstorePath:     filepath.Join(dir, envStoreFile),
+		OutputFile:       filepath.Join(dir, "output.env"),
+		SecretOutputFile: filepath.Join(dir, "secret.env"),
+	}
+	if err := e.saveOutputs(out.Outputs); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := godotenv.Read(e.OutputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"BITRISE_IPA_PATH":     "/deploy/app.ipa",
+		"BITRISE_CHANGELOG":    "first line\nsecond \"quoted\" line",
+		"BITRISE_BUILD_NUMBER": "007",
+	}, plain)
+
+	secret, err := godotenv.Read(e.SecretOutputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"BITRISE_API_TOKEN":   "secret",
+		"BITRISE_SIGNING_KEY": "key",
+	}, secret)
+}
+
+func TestSaveOutputs_NoEnvStore(t *testing.T) {
+	out := new(spec)
+	yaml.Unmarshal([]byte("outputs:\n- BITRISE_IPA_PATH:\n"), out)
+
+	e := &Execer{
+		envstorePath: filepath.Join(t.TempDir(), envStoreFile),
+		OutputFile:   filepath.Join(t.TempDir(), "output.env"),
+	}
+	assert.NoError(t, e.saveOutputs(out.Outputs))
+	assert.NoFileExists(t, e.OutputFile)
+}
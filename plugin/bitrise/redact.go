@@ -0,0 +1,60 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// mask replaces sensitive values in the step output.
+const mask = "******"
+
+// redactor is a writer that masks sensitive values before
+// writing to the underlying writer. Each write is masked
+// independently, so a value split across writes is not
+// masked.
+type redactor struct {
+	w io.Writer
+	r *strings.Replacer
+}
+
+// newRedactor returns a writer that masks the sensitive
+// values. Multi-line values are masked line by line. The
+// writer is returned unchanged if there are no values to
+// mask.
+func newRedactor(w io.Writer, values []string) io.Writer {
+	var lines []string
+	for _, value := range values {
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if w == nil || len(lines) == 0 {
+		return w
+	}
+
+	// longer values are replaced first, so that a value
+	// that contains another value is fully masked.
+	sort.SliceStable(lines, func(i, j int) bool {
+		return len(lines[i]) > len(lines[j])
+	})
+	var oldnew []string
+	for _, line := range lines {
+		oldnew = append(oldnew, line, mask)
+	}
+	return &redactor{w: w, r: strings.NewReplacer(oldnew...)}
+}
+
+// Write implements the io.Writer interface.
+func (r *redactor) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, r.r.Replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
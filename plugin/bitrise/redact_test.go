@@ -0,0 +1,28 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := newRedactor(buf, []string{"hunter2", "hunter2-long", "first\nsecond", ""})
+	fmt.Fprintln(w, "password hunter2")
+	fmt.Fprintln(w, "token hunter2-long")
+	fmt.Fprintln(w, "certificate first")
+	fmt.Fprintln(w, "second")
+	assert.Equal(t, "password ******\ntoken ******\ncertificate ******\n******\n", buf.String())
+
+	// the writer is unchanged if there are no values to
+	// mask.
+	assert.Equal(t, buf, newRedactor(buf, nil))
+	assert.Equal(t, buf, newRedactor(buf, []string{" \n"}))
+}
@@ -0,0 +1,253 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drone/plugin/cache"
+	"github.com/drone/plugin/cloner"
+	"github.com/drone/plugin/plugin/internal/versions"
+	"github.com/rogpeppe/go-internal/lockedfile"
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultSteplib is the public Bitrise step library.
+const DefaultSteplib = "https://github.com/bitrise-io/bitrise-steplib.git"
+
+// Sync loads the step index of each step library and replaces
+// the builtin step index. The step libraries are provided in
+// order of priority. A step library is a git repository, or a
+// local directory, with a step.yml for each step release at
+// steps/<id>/<version>/step.yml.
+//
+// The index of a git repository is cached, and refreshed once
+// the ttl expires. If a step library cannot be refreshed, the
+// cached index is used. If there is no cached index, the step
+// library is skipped, or replaced by the builtin index if it
+// is the public step library, and an error is returned.
+func Sync(ctx context.Context, urls []string, ttl time.Duration) error {
+	var libs []*steplib
+	var errs []error
+	for _, url := range urls {
+		url = normalizeSteplib(url)
+		if url == "" {
+			continue
+		}
+		synced, err := loadSteplib(ctx, url, ttl)
+		if err != nil {
+			errs = append(errs, err)
+			if url != DefaultSteplib {
+				continue
+			}
+			synced = index
+		}
+		libs = append(libs, &steplib{url: url, index: synced})
+	}
+	steplibs = libs
+	return errors.Join(errs...)
+}
+
+// steplib defines an indexed step library.
+type steplib struct {
+	url   string
+	index map[string]plugin
+}
+
+// steplibs is the list of synced step libraries in order of
+// priority.
+var steplibs []*steplib
+
+// helper function returns the step libraries in order of
+// priority, or the builtin index if no step library is synced.
+func sources() []*steplib {
+	if len(steplibs) == 0 {
+		return []*steplib{{url: DefaultSteplib, index: index}}
+	}
+	return steplibs
+}
+
+// helper function returns the normalized step library url.
+func normalizeSteplib(url string) string {
+	return strings.TrimSuffix(strings.TrimSpace(url), "/")
+}
+
+// helper function loads the step index from the step
+// library, or from the cache if the cached index is fresh.
+func loadSteplib(ctx context.Context, steplib string, ttl time.Duration) (map[string]plugin, error) {
+	// a local step library is always read from disk, since
+	// it is not fetched.
+	if info, err := os.Stat(steplib); err == nil && info.IsDir() {
+		steps, err := readSteplib(steplib)
+		if err != nil {
+			return nil, err
+		}
+		return steps.index(), nil
+	}
+
+	key := cache.GetKeyName("steplib" + steplib)
+	path := filepath.Join(key, "index.json")
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < ttl {
+		steps, err := readSteplibCache(path)
+		if err == nil {
+			slog.Debug("using cached steplib", slog.String("steplib", steplib), slog.Time("updated", info.ModTime()))
+			return steps.index(), nil
+		}
+		slog.Warn("cannot read cached steplib", slog.String("steplib", steplib), "error", err)
+	}
+
+	steps, err := fetchSteplib(ctx, steplib, key)
+	if err == nil {
+		if werr := writeSteplibCache(path, steps); werr != nil {
+			slog.Warn("cannot cache steplib", slog.String("steplib", steplib), "error", werr)
+		}
+		slog.Info("synced steplib", slog.String("steplib", steplib), slog.Int("steps", len(steps)))
+		return steps.index(), nil
+	}
+
+	// fallback to the stale cached index if the step
+	// library cannot be fetched (e.g. offline).
+	if stale, serr := readSteplibCache(path); serr == nil {
+		slog.Warn("cannot sync steplib, using cached steplib", slog.String("steplib", steplib), "error", err)
+		return stale.index(), nil
+	}
+	return nil, err
+}
+
+// helper function clones the step library into a temporary
+// directory and reads the step index.
+func fetchSteplib(ctx context.Context, steplib, key string) (steplibIndex, error) {
+	if err := os.MkdirAll(key, 0700); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(key, "clone")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	params := cloner.Params{Repo: steplib, Dir: dir}
+	if err := cloner.New(1, io.Discard).Clone(ctx, params); err != nil {
+		return nil, fmt.Errorf("cannot clone steplib %s: %w", steplib, err)
+	}
+	return readSteplib(dir)
+}
+
+// steplibIndex is the step index, keyed by step id, cached
+// in json format.
+type steplibIndex map[string]map[string]steplibRelease
+
+// steplibRelease defines a step release.
+type steplibRelease struct {
+	Repo   string `json:"repo"`
+	Commit string `json:"commit"`
+}
+
+// helper function reads the step index from the step library
+// directory.
+func readSteplib(dir string) (steplibIndex, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "steps", "*", "*", "step.yml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no steps found in steplib %s", dir)
+	}
+
+	steps := steplibIndex{}
+	for _, match := range matches {
+		raw, err := os.ReadFile(match)
+		if err != nil {
+			return nil, err
+		}
+		step := new(struct {
+			Source struct {
+				Git    string `yaml:"git"`
+				Commit string `yaml:"commit"`
+			} `yaml:"source"`
+		})
+		if err := yaml.Unmarshal(raw, step); err != nil {
+			slog.Debug("cannot parse step", slog.String("path", match), "error", err)
+			continue
+		}
+		if step.Source.Git == "" {
+			continue
+		}
+
+		// extract the id and version from the path
+		version := filepath.Base(filepath.Dir(match))
+		id := filepath.Base(filepath.Dir(filepath.Dir(match)))
+		if steps[id] == nil {
+			steps[id] = map[string]steplibRelease{}
+		}
+		steps[id][version] = steplibRelease{
+			Repo:   step.Source.Git,
+			Commit: step.Source.Commit,
+		}
+	}
+	return steps, nil
+}
+
+// helper function reads the cached step index.
+func readSteplibCache(path string) (steplibIndex, error) {
+	raw, err := lockedfile.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	steps := steplibIndex{}
+	if err := json.Unmarshal(raw, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// helper function writes the cached step index.
+func writeSteplibCache(path string, steps steplibIndex) error {
+	raw, err := json.Marshal(steps)
+	if err != nil {
+		return err
+	}
+	return lockedfile.Write(path, bytes.NewReader(raw), 0600)
+}
+
+// index returns the step lookup table. The default version of
+// each step is the latest stable release.
+func (s steplibIndex) index() map[string]plugin {
+	out := map[string]plugin{}
+	for id, releases := range s {
+		plugin_ := plugin{
+			name:     id,
+			releases: map[string]release{},
+		}
+		var available []string
+		for version, release_ := range releases {
+			plugin_.releases[version] = release{
+				version: version,
+				repo:    release_.Repo,
+				commit:  release_.Commit,
+			}
+			available = append(available, version)
+		}
+		if latest, ok := versions.Resolve(versions.Latest, available); ok {
+			plugin_.version = latest
+		} else {
+			sort.Strings(available)
+			plugin_.version = available[len(available)-1]
+		}
+		out[id] = plugin_
+	}
+	return out
+}
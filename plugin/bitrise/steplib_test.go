@@ -0,0 +1,209 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bitrise
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// helper function writes a step library with the step
+// releases to the directory.
+func writeSteplib(t *testing.T, dir string, releases map[string]string) {
+	for path, commit := range releases {
+		if err := os.MkdirAll(filepath.Join(dir, "steps", path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "steps", path, "step.yml"), []byte(
+			"source:\n  git: https://github.com/octocat/steps-hello.git\n  commit: "+commit+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// helper function commits the directory to a new git
+// repository.
+func commitSteplib(t *testing.T, dir string) {
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, _ := r.Worktree()
+	w.AddGlob(".")
+	_, err = w.Commit("steplib", &git.CommitOptions{
+		Author: &object.Signature{Name: "octocat", Email: "octocat@github.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// helper function restores the synced step libraries.
+func restoreSteplibs(saved []*steplib) {
+	steplibs = saved
+}
+
+func TestSync_Local(t *testing.T) {
+	defer restoreSteplibs(steplibs)
+
+	dir := t.TempDir()
+	writeSteplib(t, dir, map[string]string{
+		"hello/1.0.0":      "a",
+		"hello/1.1.0":      "b",
+		"hello/2.0.0-beta": "c",
+	})
+	if err := Sync(context.Background(), []string{dir}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	_, commit, ok := Lookup("hello", "")
+	if !ok {
+		t.Errorf("Expect found step")
+	}
+	if got, want := commit, "b"; got != want {
+		t.Errorf("Expect default version commit %s, got %s", want, got)
+	}
+	if _, commit, _ = Lookup("hello", "2.0.0-beta"); commit != "c" {
+		t.Errorf("Expect commit c, got %s", commit)
+	}
+
+	// the builtin index is replaced by the step library.
+	if _, _, ok := Lookup("activate-ssh-key", ""); ok {
+		t.Errorf("Expect builtin step not found")
+	}
+}
+
+func TestSync_Cache(t *testing.T) {
+	defer restoreSteplibs(steplibs)
+	t.Setenv("HOME", t.TempDir())
+
+	repo := t.TempDir()
+	writeSteplib(t, repo, map[string]string{"hello/1.0.0": "a"})
+	commitSteplib(t, repo)
+
+	// the step library is cloned and cached.
+	url := "file://" + repo
+	if err := Sync(context.Background(), []string{url}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, commit, _ := Lookup("hello", ""); commit != "a" {
+		t.Errorf("Expect commit a, got %s", commit)
+	}
+
+	// the cached index is used until the ttl expires.
+	os.RemoveAll(repo)
+	writeSteplib(t, repo, map[string]string{"hello/1.1.0": "b"})
+	commitSteplib(t, repo)
+	if err := Sync(context.Background(), []string{url}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, commit, _ := Lookup("hello", ""); commit != "a" {
+		t.Errorf("Expect cached commit a, got %s", commit)
+	}
+
+	// the step library is refreshed once the ttl expires.
+	if err := Sync(context.Background(), []string{url}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, commit, _ := Lookup("hello", ""); commit != "b" {
+		t.Errorf("Expect refreshed commit b, got %s", commit)
+	}
+
+	// the stale cached index is used if the step library
+	// cannot be refreshed.
+	os.RemoveAll(repo)
+	if err := Sync(context.Background(), []string{url}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, commit, _ := Lookup("hello", ""); commit != "b" {
+		t.Errorf("Expect stale commit b, got %s", commit)
+	}
+}
+
+func TestSync_Error(t *testing.T) {
+	defer restoreSteplibs(steplibs)
+	t.Setenv("HOME", t.TempDir())
+
+	url := "file://" + filepath.Join(t.TempDir(), "missing")
+	if err := Sync(context.Background(), []string{url}, time.Hour); err == nil {
+		t.Errorf("Expect error syncing missing steplib")
+	}
+
+	// the builtin index is used if the step library cannot
+	// be synced.
+	if _, _, ok := Lookup("activate-ssh-key", ""); !ok {
+		t.Errorf("Expect builtin step found")
+	}
+}
+
+func TestSync_Priority(t *testing.T) {
+	defer restoreSteplibs(steplibs)
+
+	private := t.TempDir()
+	writeSteplib(t, private, map[string]string{
+		"hello/1.0.0":  "private-hello",
+		"deploy/1.0.0": "private-deploy",
+	})
+	public := t.TempDir()
+	writeSteplib(t, public, map[string]string{
+		"hello/1.0.0":  "public-hello",
+		"hello/2.0.0":  "public-hello-2",
+		"deploy/2.0.0": "public-deploy",
+		"lint/1.0.0":   "public-lint",
+	})
+	if err := Sync(context.Background(), []string{private, public}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		commit string
+		ok     bool
+	}{
+		{name: "hello", commit: "private-hello", ok: true},
+		{name: "hello@1.0.0", commit: "private-hello", ok: true},
+		{name: "lint", commit: "public-lint", ok: true},
+		// the step library with the highest priority that
+		// provides the step is authoritative.
+		{name: "hello@2.0.0", commit: "", ok: false},
+		{name: "deploy@2.0.0", commit: "", ok: false},
+		// the step library may be provided explicitly.
+		{name: public + "::hello@2.0.0", commit: "public-hello-2", ok: true},
+		{name: public + "/::deploy", commit: "public-deploy", ok: true},
+		{name: private + "::lint", commit: "", ok: false},
+		{name: "https://example.com/steplib.git::hello", commit: "", ok: false},
+	}
+	for _, test := range tests {
+		_, commit, ok := ParseLookup(test.name)
+		if got, want := ok, test.ok; got != want {
+			t.Errorf("Expect step %s found %v, got %v", test.name, want, got)
+		}
+		if got, want := commit, test.commit; got != want {
+			t.Errorf("Expect step %s commit %s, got %s", test.name, want, got)
+		}
+	}
+}
+
+func TestSteplib(t *testing.T) {
+	tests := []struct {
+		step, steplib string
+	}{
+		{"activate-ssh-key@4", ""},
+		{"git::https://github.com/octocat/steps-hello.git@main", ""},
+		{"https://github.com/octocat/bitrise-steplib.git::hello@1.0.0", "https://github.com/octocat/bitrise-steplib.git"},
+		{"/opt/steplib::hello", "/opt/steplib"},
+	}
+	for _, test := range tests {
+		if got, want := Steplib(test.step), test.steplib; got != want {
+			t.Errorf("Expect step %s steplib %q, got %q", test.step, want, got)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultHost is the default docker engine api host.
+	DefaultHost = "unix:///var/run/docker.sock"
+
+	// apiVersion is the docker engine api version used
+	// when making requests.
+	apiVersion = "v1.41"
+)
+
+type (
+	// Client is a minimal docker engine api client that
+	// supports running a container to completion.
+	Client struct {
+		client *http.Client
+		base   string
+	}
+
+	// RunConfig provides the container run configuration.
+	RunConfig struct {
+		Image   string
+		Env     []string
+		Workdir string
+		Binds   []string
+		Stdout  io.Writer
+		Stderr  io.Writer
+
+		// StopTimeout is the time to wait for the container
+		// to exit after it is stopped, before it is killed.
+		StopTimeout time.Duration
+	}
+
+	// ExitError is returned when the container exits
+	// with a non-zero exit code.
+	ExitError struct {
+		Image string
+		Code  int
+	}
+)
+
+// Error implements the error interface.
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("docker: %s exited with code %d", e.Image, e.Code)
+}
+
+// NewClient returns a new docker client for the given host
+// (e.g. unix:///var/run/docker.sock or tcp://127.0.0.1:2375).
+// If the host is empty the DOCKER_HOST environment variable
+// is used, falling back to the default unix socket. TLS is
+// configured using the DOCKER_TLS_VERIFY and DOCKER_CERT_PATH
+// environment variables.
+func NewClient(host string) (*Client, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = DefaultHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid docker host: %s", host))
+	}
+
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		return &Client{
+			client: &http.Client{Transport: transport},
+			base:   "http://docker",
+		}, nil
+	case "tcp", "http", "https":
+		// tls is enabled if DOCKER_TLS_VERIFY is set, using
+		// the certificates in DOCKER_CERT_PATH, consistent
+		// with the docker cli.
+		if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			config, err := tlsConfig(os.Getenv("DOCKER_CERT_PATH"))
+			if err != nil {
+				return nil, err
+			}
+			return &Client{
+				client: &http.Client{Transport: &http.Transport{TLSClientConfig: config}},
+				base:   "https://" + u.Host,
+			}, nil
+		}
+		scheme := "http"
+		if u.Scheme == "https" {
+			scheme = "https"
+		}
+		return &Client{
+			client: http.DefaultClient,
+			base:   scheme + "://" + u.Host,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker host: %s", host)
+	}
+}
+
+// helper function returns the tls configuration using the
+// ca.pem, cert.pem and key.pem files in the certificate
+// directory, which defaults to ~/.docker. The client
+// certificate is optional.
+func tlsConfig(dir string) (*tls.Config, error) {
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot find docker certificates")
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read docker ca certificate")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid docker ca certificate: %s", filepath.Join(dir, "ca.pem"))
+	}
+	config := &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read docker client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Run pulls the image if not present, creates and starts
+// the container, streams the container logs and waits for
+// the container to exit. The container is always removed.
+func (c *Client) Run(ctx context.Context, conf *RunConfig) error {
+	image := expandImage(conf.Image)
+	if err := c.pullIfNotExists(ctx, image); err != nil {
+		return err
+	}
+
+	id, err := c.create(ctx, image, conf)
+	if err != nil {
+		return err
+	}
+	defer c.remove(context.Background(), id)
+
+	// if the context is cancelled the container is stopped
+	// gracefully before it is removed.
+	defer func() {
+		if ctx.Err() != nil {
+			c.stop(context.Background(), id, conf.StopTimeout)
+		}
+	}()
+
+	if err := c.start(ctx, id); err != nil {
+		return err
+	}
+	if err := c.logs(ctx, id, conf.Stdout, conf.Stderr); err != nil {
+		return err
+	}
+
+	code, err := c.wait(ctx, id)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &ExitError{Image: conf.Image, Code: code}
+	}
+	return nil
+}
+
+func (c *Client) pullIfNotExists(ctx context.Context, image string) error {
+	res, err := c.do(ctx, "GET", "/images/"+image+"/json", nil, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("fromImage", image)
+	res, err = c.do(ctx, "POST", "/images/create", params, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("cannot pull image %s", image))
+	}
+
+	// the pull progress is streamed as a sequence of json
+	// messages. the pull failed if any message contains
+	// an error.
+	dec := json.NewDecoder(res.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("cannot pull image %s", image))
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("cannot pull image %s: %s", image, msg.Error)
+		}
+	}
+}
+
+func (c *Client) create(ctx context.Context, image string, conf *RunConfig) (string, error) {
+	in := map[string]interface{}{
+		"Image":      image,
+		"Env":        conf.Env,
+		"WorkingDir": conf.Workdir,
+		"HostConfig": map[string]interface{}{
+			"Binds": conf.Binds,
+		},
+	}
+	res, err := c.do(ctx, "POST", "/containers/create", nil, in)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return "", errors.Wrap(err, "cannot create container")
+	}
+
+	out := struct {
+		ID string `json:"Id"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", errors.Wrap(err, "cannot create container")
+	}
+	return out.ID, nil
+}
+
+func (c *Client) start(ctx context.Context, id string) error {
+	res, err := c.do(ctx, "POST", "/containers/"+id+"/start", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return errors.Wrap(checkResponse(res), "cannot start container")
+}
+
+func (c *Client) logs(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	params := url.Values{}
+	params.Set("follow", "1")
+	params.Set("stdout", "1")
+	params.Set("stderr", "1")
+	res, err := c.do(ctx, "GET", "/containers/"+id+"/logs", params, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return errors.Wrap(err, "cannot stream container logs")
+	}
+	return demux(res.Body, stdout, stderr)
+}
+
+func (c *Client) wait(ctx context.Context, id string) (int, error) {
+	res, err := c.do(ctx, "POST", "/containers/"+id+"/wait", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return 0, errors.Wrap(err, "cannot wait for container")
+	}
+
+	out := struct {
+		StatusCode int `json:"StatusCode"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return 0, errors.Wrap(err, "cannot wait for container")
+	}
+	return out.StatusCode, nil
+}
+
+func (c *Client) stop(ctx context.Context, id string, timeout time.Duration) error {
+	params := url.Values{}
+	params.Set("t", strconv.Itoa(int(timeout.Seconds())))
+	res, err := c.do(ctx, "POST", "/containers/"+id+"/stop", params, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (c *Client) remove(ctx context.Context, id string) error {
+	params := url.Values{}
+	params.Set("force", "1")
+	res, err := c.do(ctx, "DELETE", "/containers/"+id, params, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// helper function creates and sends a docker engine api
+// request. The request body, if provided, is json encoded.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, in interface{}) (*http.Response, error) {
+	endpoint := c.base + "/" + apiVersion + path
+	if len(params) > 0 {
+		endpoint = endpoint + "?" + params.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		buf := new(bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(in); err != nil {
+			return nil, err
+		}
+		body = buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.client.Do(req)
+}
+
+// helper function returns an error if the docker engine
+// api response status code is not successful.
+func checkResponse(res *http.Response) error {
+	if res.StatusCode < 300 {
+		return nil
+	}
+	out := struct {
+		Message string `json:"message"`
+	}{}
+	json.NewDecoder(res.Body).Decode(&out)
+	if out.Message == "" {
+		out.Message = http.StatusText(res.StatusCode)
+	}
+	return fmt.Errorf("docker: %s (status %d)", out.Message, res.StatusCode)
+}
+
+// helper function demultiplexes the docker log stream. Each
+// frame is prefixed with an 8 byte header where the first
+// byte identifies the stream and the last four bytes encode
+// the frame size.
+func demux(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if w == nil {
+			w = io.Discard
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// helper function appends the latest tag to the image name
+// if no tag or digest is provided. The docker engine pulls
+// all tags if the tag is omitted.
+func expandImage(image string) string {
+	if strings.Contains(image, "@") {
+		return image
+	}
+	name := image
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		name = name[i+1:]
+	}
+	if !strings.Contains(name, ":") {
+		return image + ":latest"
+	}
+	return image
+}
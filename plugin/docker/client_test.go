@@ -0,0 +1,238 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEngine is a fake docker engine api served over a
+// unix socket for testing purposes.
+type fakeEngine struct {
+	images  map[string]bool
+	created map[string]interface{}
+	pulled  []string
+	removed bool
+	stopped bool
+	code    int
+	block   chan struct{}
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(path, "/images/"):
+		image := strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")
+		if !f.images[image] {
+			w.WriteHeader(404)
+		}
+	case r.Method == "POST" && path == "/images/create":
+		f.pulled = append(f.pulled, r.URL.Query().Get("fromImage"))
+		w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Done"}`))
+	case r.Method == "POST" && path == "/containers/create":
+		json.NewDecoder(r.Body).Decode(&f.created)
+		w.WriteHeader(201)
+		w.Write([]byte(`{"Id":"abc123"}`))
+	case r.Method == "POST" && path == "/containers/abc123/start":
+		w.WriteHeader(204)
+	case r.Method == "GET" && path == "/containers/abc123/logs":
+		w.Write(frame(1, "hello stdout\n"))
+		w.Write(frame(2, "hello stderr\n"))
+	case r.Method == "POST" && path == "/containers/abc123/wait":
+		if f.block != nil {
+			<-f.block
+		}
+		json.NewEncoder(w).Encode(map[string]int{"StatusCode": f.code})
+	case r.Method == "POST" && path == "/containers/abc123/stop":
+		f.stopped = true
+		close(f.block)
+		w.WriteHeader(204)
+	case r.Method == "DELETE" && path == "/containers/abc123":
+		f.removed = true
+		w.WriteHeader(204)
+	default:
+		w.WriteHeader(404)
+		w.Write([]byte(`{"message":"not found"}`))
+	}
+}
+
+func frame(stream byte, s string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(s)))
+	return append(header, s...)
+}
+
+func serve(t *testing.T, h http.Handler) string {
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("unix sockets not supported")
+	}
+	srv := &http.Server{Handler: h}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return "unix://" + sock
+}
+
+func TestRun(t *testing.T) {
+	engine := &fakeEngine{images: map[string]bool{}}
+	client, err := NewClient(serve(t, engine))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	err = client.Run(context.Background(), &RunConfig{
+		Image:   "plugins/webhook",
+		Env:     []string{"PLUGIN_URLS=http://example.com"},
+		Workdir: "/drone/src",
+		Binds:   []string{"/drone/src:/drone/src"},
+		Stdout:  stdout,
+		Stderr:  stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := engine.pulled, []string{"plugins/webhook:latest"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Want image pulled %v, got %v", want, got)
+	}
+	if got, want := engine.created["WorkingDir"], "/drone/src"; got != want {
+		t.Errorf("Want working dir %q, got %q", want, got)
+	}
+	if got, want := stdout.String(), "hello stdout\n"; got != want {
+		t.Errorf("Want stdout %q, got %q", want, got)
+	}
+	if got, want := stderr.String(), "hello stderr\n"; got != want {
+		t.Errorf("Want stderr %q, got %q", want, got)
+	}
+	if !engine.removed {
+		t.Errorf("Want container removed")
+	}
+}
+
+func TestRun_Exists(t *testing.T) {
+	engine := &fakeEngine{images: map[string]bool{"alpine:3": true}}
+	client, err := NewClient(serve(t, engine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Run(context.Background(), &RunConfig{Image: "alpine:3"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(engine.pulled) != 0 {
+		t.Errorf("Want existing image not pulled, got %v", engine.pulled)
+	}
+}
+
+func TestRun_ExitCode(t *testing.T) {
+	engine := &fakeEngine{images: map[string]bool{"alpine:3": true}, code: 2}
+	client, err := NewClient(serve(t, engine))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Run(context.Background(), &RunConfig{Image: "alpine:3"})
+	exitErr := new(ExitError)
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Want exit error, got %v", err)
+	}
+	if got, want := exitErr.Code, 2; got != want {
+		t.Errorf("Want exit code %d, got %d", want, got)
+	}
+	if !engine.removed {
+		t.Errorf("Want container removed")
+	}
+}
+
+func TestRun_Cancel(t *testing.T) {
+	engine := &fakeEngine{images: map[string]bool{"alpine:3": true}, block: make(chan struct{})}
+	client, err := NewClient(serve(t, engine))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := client.Run(ctx, &RunConfig{Image: "alpine:3"}); err == nil {
+		t.Errorf("Want error when context is cancelled")
+	}
+	if !engine.stopped {
+		t.Errorf("Want container stopped")
+	}
+	if !engine.removed {
+		t.Errorf("Want container removed")
+	}
+}
+
+func TestRun_TLS(t *testing.T) {
+	engine := &fakeEngine{images: map[string]bool{"alpine:3": true}}
+	srv := httptest.NewTLSServer(engine)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(filepath.Join(dir, "ca.pem"), ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_TLS_VERIFY", "1")
+	t.Setenv("DOCKER_CERT_PATH", dir)
+
+	client, err := NewClient("tcp://" + srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(client.base, "https://") {
+		t.Errorf("Want https base url, got %s", client.base)
+	}
+	err = client.Run(context.Background(), &RunConfig{Image: "alpine:3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !engine.removed {
+		t.Errorf("Want container removed")
+	}
+}
+
+func TestNewClient_TLSError(t *testing.T) {
+	t.Setenv("DOCKER_TLS_VERIFY", "1")
+	t.Setenv("DOCKER_CERT_PATH", t.TempDir())
+
+	// plaintext requests are not sent to a tls daemon if
+	// the certificates cannot be loaded.
+	if _, err := NewClient("tcp://127.0.0.1:2376"); err == nil {
+		t.Errorf("Want error when docker certificates are missing")
+	}
+}
+
+func TestExpandImage(t *testing.T) {
+	tests := map[string]string{
+		"alpine":                          "alpine:latest",
+		"alpine:3":                        "alpine:3",
+		"localhost:5000/foo/bar":          "localhost:5000/foo/bar:latest",
+		"localhost:5000/foo/bar:1":        "localhost:5000/foo/bar:1",
+		"alpine@sha256:0123456789abcdef0": "alpine@sha256:0123456789abcdef0",
+	}
+	for in, want := range tests {
+		if got := expandImage(in); got != want {
+			t.Errorf("Want image %q expanded to %q, got %q", in, want, got)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slog"
+)
+
+// apt keyring and source directories. These are variables
+// so that they can be overridden for testing purposes.
+var (
+	aptKeyringDir = "/etc/apt/keyrings"
+	aptSourcesDir = "/etc/apt/sources.list.d"
+)
+
+// aptNamePattern matches valid apt source names, which are
+// used as the keyring and source file names.
+var aptNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// apt source file formats.
+const (
+	aptFormatList   = "list"
+	aptFormatDeb822 = "deb822"
+)
+
+// installAptSources installs the apt repository signing keys
+// into dedicated keyrings and writes a source file for each
+// repository, scoped to the keyring using signed-by. The
+// list of sources that failed to install is returned.
+func (e *Execer) installAptSources(ctx context.Context, sources []*AptSource) []string {
+	var failed []string
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+		if source.Key == "" || source.Data == "" {
+			slog.Info("Either key or data is not set", slog.String("source", source.Data), slog.String("key", source.Key))
+			continue
+		}
+
+		slog.Info("add-apt-repository", slog.String("source", source.Data), slog.String("key", source.Key))
+		paths, err := e.installAptSource(ctx, source)
+		if err != nil {
+			slog.Error("add-apt-repository failed", slog.String("source", source.Data), "error", err)
+			failed = append(failed, "apt-source:"+source.Data)
+			continue
+		}
+		if source.Cleanup {
+			e.cleanups = append(e.cleanups, func() {
+				e.removeFiles(context.Background(), paths...)
+			})
+		}
+	}
+	return failed
+}
+
+// installAptSource installs the apt repository signing key
+// and source file, and returns the paths of the installed
+// files.
+func (e *Execer) installAptSource(ctx context.Context, source *AptSource) ([]string, error) {
+	if source.Name != "" && !isValidAptName(source.Name) {
+		return nil, fmt.Errorf("invalid apt source name: %s", source.Name)
+	}
+
+	key, err := downloadKey(ctx, source.Key)
+	if err != nil {
+		return nil, err
+	}
+	if source.Fingerprint != "" {
+		if err := verifyKey(key, source.Fingerprint); err != nil {
+			return nil, err
+		}
+	}
+
+	name := source.Name
+	if name == "" {
+		name = "plugin-" + shortHash(source.Key+source.Data)
+	}
+
+	// apt supports ascii armored keyrings if the file uses
+	// the .asc extension, which removes the dependency on
+	// gpg to dearmor the key.
+	keyring := filepath.Join(aptKeyringDir, name+".gpg")
+	if isArmored(key) {
+		keyring = filepath.Join(aptKeyringDir, name+".asc")
+	}
+
+	var path string
+	var data []byte
+	switch source.Format {
+	case "", aptFormatList:
+		line, err := signedLine(source.Data, keyring)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(aptSourcesDir, name+".list")
+		data = []byte(line + "\n")
+	case aptFormatDeb822, "sources":
+		stanza, err := deb822(source.Data, keyring)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(aptSourcesDir, name+".sources")
+		data = []byte(stanza)
+	default:
+		return nil, fmt.Errorf("unsupported apt source format: %s", source.Format)
+	}
+
+	if err := e.writeFile(ctx, keyring, key); err != nil {
+		return nil, err
+	}
+	if err := e.writeFile(ctx, path, data); err != nil {
+		return nil, err
+	}
+	return []string{path, keyring}, nil
+}
+
+// helper function returns true if the apt source name is a
+// valid file name, which prevents the keyring and source
+// files from being written outside of the apt directories.
+func isValidAptName(name string) bool {
+	return aptNamePattern.MatchString(name) && name != "." && name != ".."
+}
+
+// writeFile writes the data to the system path. The file is
+// written to a temporary location and installed with elevated
+// privileges, if required. The file is not re-written if the
+// contents are unchanged.
+func (e *Execer) writeFile(ctx context.Context, path string, data []byte) error {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		slog.Debug("file unchanged", slog.String("path", path))
+		return nil
+	}
+
+	tmp, err := os.CreateTemp("", "apt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	cmd := command(ctx, sudo("install", "-D", "-m", "0644", tmp.Name(), path))
+	return runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir, e.Stdout, e.Stderr)
+}
+
+// removeFiles removes the system files with elevated
+// privileges, if required.
+func (e *Execer) removeFiles(ctx context.Context, paths ...string) {
+	cmd := command(ctx, sudo(append([]string{"rm", "-f"}, paths...)...))
+	if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir, e.Stdout, e.Stderr); err != nil {
+		slog.Error("failed to remove files", slog.String("paths", strings.Join(paths, ", ")), "error", err)
+	}
+}
+
+// signedLine returns the one-line-style apt source with the
+// signed-by option set to the keyring path.
+func signedLine(line, keyring string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || (fields[0] != "deb" && fields[0] != "deb-src") {
+		return "", fmt.Errorf("invalid apt source: %s", line)
+	}
+	opt := "signed-by=" + keyring
+
+	// append signed-by to existing options, replacing
+	// any signed-by option already present.
+	if strings.HasPrefix(fields[1], "[") {
+		var opts []string
+		i := 1
+		for ; i < len(fields); i++ {
+			f := strings.TrimSuffix(strings.TrimPrefix(fields[i], "["), "]")
+			if f != "" && !strings.HasPrefix(f, "signed-by=") {
+				opts = append(opts, f)
+			}
+			if strings.HasSuffix(fields[i], "]") {
+				break
+			}
+		}
+		opts = append(opts, opt)
+		rest := fields[i+1:]
+		return strings.Join(append([]string{fields[0], "[" + strings.Join(opts, " ") + "]"}, rest...), " "), nil
+	}
+	return strings.Join(append([]string{fields[0], "[" + opt + "]"}, fields[1:]...), " "), nil
+}
+
+// deb822 converts the one-line-style apt source to a deb822
+// style source stanza with the signed-by field set to the
+// keyring path.
+func deb822(line, keyring string) (string, error) {
+	signed, err := signedLine(line, keyring)
+	if err != nil {
+		return "", err
+	}
+
+	// the signed line always includes options in the
+	// second field, enclosed in brackets.
+	start := strings.Index(signed, "[")
+	end := strings.Index(signed, "]")
+	kind := strings.TrimSpace(signed[:start])
+	opts := strings.Fields(signed[start+1 : end])
+	rest := strings.Fields(signed[end+1:])
+	if len(rest) < 2 {
+		return "", fmt.Errorf("invalid apt source: %s", line)
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Types: %s\n", kind)
+	fmt.Fprintf(sb, "URIs: %s\n", rest[0])
+	fmt.Fprintf(sb, "Suites: %s\n", rest[1])
+	if len(rest) > 2 {
+		fmt.Fprintf(sb, "Components: %s\n", strings.Join(rest[2:], " "))
+	}
+	for _, opt := range opts {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "signed-by":
+			fmt.Fprintf(sb, "Signed-By: %s\n", parts[1])
+		case "arch":
+			fmt.Fprintf(sb, "Architectures: %s\n", strings.ReplaceAll(parts[1], ",", " "))
+		case "lang":
+			fmt.Fprintf(sb, "Languages: %s\n", strings.ReplaceAll(parts[1], ",", " "))
+		case "trusted":
+			fmt.Fprintf(sb, "Trusted: %s\n", parts[1])
+		}
+	}
+	return sb.String(), nil
+}
+
+// verifyKey returns an error if the key does not contain
+// a public key with the given fingerprint.
+func verifyKey(key []byte, fingerprint string) error {
+	want := strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+	want = strings.TrimPrefix(want, "0X")
+
+	var keyring openpgp.EntityList
+	var err error
+	if isArmored(key) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(key))
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read apt key")
+	}
+
+	for _, entity := range keyring {
+		if strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)) == want {
+			return nil
+		}
+		for _, sub := range entity.Subkeys {
+			if strings.ToUpper(hex.EncodeToString(sub.PublicKey.Fingerprint)) == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("apt key fingerprint mismatch: want %s", fingerprint)
+}
+
+// helper function downloads the apt repository key.
+func downloadKey(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid apt key url: %s", url))
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to download apt key: %s", url))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download apt key: %s: %s", url, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// helper function returns true if the key is ascii armored.
+func isArmored(key []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN PGP"))
+}
+
+// helper function returns a short hash of the string.
+func shortHash(s string) string {
+	h := sha1.Sum([]byte(s))
+	return hex.EncodeToString(h[:])[:12]
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedLine(t *testing.T) {
+	keyring := "/etc/apt/keyrings/example.asc"
+	tests := []struct {
+		line, expected string
+	}{
+		{
+			line:     "deb https://apt.example.com stable main",
+			expected: "deb [signed-by=/etc/apt/keyrings/example.asc] https://apt.example.com stable main",
+		},
+		{
+			line:     "deb [arch=amd64] https://apt.example.com stable main",
+			expected: "deb [arch=amd64 signed-by=/etc/apt/keyrings/example.asc] https://apt.example.com stable main",
+		},
+		{
+			line:     "deb [ arch=amd64 signed-by=/usr/share/keyrings/old.gpg ] https://apt.example.com stable main",
+			expected: "deb [arch=amd64 signed-by=/etc/apt/keyrings/example.asc] https://apt.example.com stable main",
+		},
+	}
+	for _, tt := range tests {
+		got, err := signedLine(tt.line, keyring)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, got)
+	}
+
+	_, err := signedLine("https://apt.example.com stable main", keyring)
+	assert.Error(t, err)
+}
+
+func TestDeb822(t *testing.T) {
+	got, err := deb822("deb [arch=amd64,arm64] https://apt.example.com stable main contrib", "/etc/apt/keyrings/example.asc")
+	assert.NoError(t, err)
+	assert.Equal(t, `Types: deb
+URIs: https://apt.example.com
+Suites: stable
+Components: main contrib
+Architectures: amd64 arm64
+Signed-By: /etc/apt/keyrings/example.asc
+`, got)
+}
+
+func TestVerifyKey(t *testing.T) {
+	key, fingerprint := testKey(t)
+	assert.NoError(t, verifyKey(key, fingerprint))
+	assert.NoError(t, verifyKey(key, "0x"+fingerprint))
+	assert.Error(t, verifyKey(key, "0000000000000000000000000000000000000000"))
+	assert.Error(t, verifyKey([]byte("invalid"), fingerprint))
+}
+
+func TestIsValidAptName(t *testing.T) {
+	assert.True(t, isValidAptName("example"))
+	assert.True(t, isValidAptName("docker-ce_stable.1"))
+	assert.False(t, isValidAptName(""))
+	assert.False(t, isValidAptName("."))
+	assert.False(t, isValidAptName(".."))
+	assert.False(t, isValidAptName("../../etc/passwd"))
+	assert.False(t, isValidAptName("example/list"))
+	assert.False(t, isValidAptName(`example\list`))
+	assert.False(t, isValidAptName("example list"))
+}
+
+func TestInstallAptSource_InvalidName(t *testing.T) {
+	e := &Execer{Stdout: io.Discard, Stderr: io.Discard}
+	_, err := e.installAptSource(context.Background(), &AptSource{
+		Name: "../../etc/cron.d/example",
+		Key:  "https://apt.example.com/key.asc",
+		Data: "deb https://apt.example.com stable main",
+	})
+	assert.EqualError(t, err, "invalid apt source name: ../../etc/cron.d/example")
+}
+
+func TestInstallAptSource(t *testing.T) {
+	if _, err := exec.LookPath("install"); err != nil {
+		t.Skip("install command required")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("root required to avoid sudo")
+	}
+
+	key, fingerprint := testKey(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(key)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	aptKeyringDir, aptSourcesDir = filepath.Join(dir, "keyrings"), filepath.Join(dir, "sources.list.d")
+	defer func() {
+		aptKeyringDir, aptSourcesDir = "/etc/apt/keyrings", "/etc/apt/sources.list.d"
+	}()
+
+	e := &Execer{Stdout: io.Discard, Stderr: io.Discard}
+	source := &AptSource{
+		Name:        "example",
+		Key:         srv.URL,
+		Data:        "deb https://apt.example.com stable main",
+		Fingerprint: fingerprint,
+	}
+
+	// install the source twice to verify the source
+	// file is not duplicated.
+	for i := 0; i < 2; i++ {
+		paths, err := e.installAptSource(context.Background(), source)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(aptSourcesDir, "example.list"),
+			filepath.Join(aptKeyringDir, "example.asc"),
+		}, paths)
+	}
+
+	list, _ := os.ReadFile(filepath.Join(aptSourcesDir, "example.list"))
+	assert.Equal(t, "deb [signed-by="+filepath.Join(aptKeyringDir, "example.asc")+"] https://apt.example.com stable main\n", string(list))
+
+	keyring, _ := os.ReadFile(filepath.Join(aptKeyringDir, "example.asc"))
+	assert.Equal(t, key, keyring)
+
+	source.Fingerprint = "0000000000000000000000000000000000000000"
+	_, err := e.installAptSource(context.Background(), source)
+	assert.Error(t, err)
+}
+
+// helper function generates an armored public key for
+// testing purposes.
+func testKey(t *testing.T) ([]byte, string) {
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.Bytes(), hex.EncodeToString(entity.PrimaryKey.Fingerprint)
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/drone/plugin/plugin/internal/environ"
+	"github.com/pkg/errors"
+)
+
+// expandArgs expands environment variables in the script
+// arguments and executes each argument as a template, with
+// the plugin inputs provided as template data. For example,
+// the argument --url={{ .url }} is rendered with the value
+// of the PLUGIN_URL environment variable.
+func expandArgs(args []string, env []string) ([]string, error) {
+	envs := environ.Map(env)
+	data := inputValues(envs)
+
+	var out []string
+	for _, arg := range args {
+		val, err := expand(arg, envs, data)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid argument: %s", arg))
+		}
+		out = append(out, val)
+	}
+	return out, nil
+}
+
+// expand executes the string as a template with the plugin
+// inputs provided as template data, and expands environment
+// variables in the result.
+func expand(s string, envs, data map[string]string) (string, error) {
+	// the template is executed before the environment variables
+	// are expanded, so that environment variable values are not
+	// parsed as a template. The dollar signs in the input values
+	// are escaped, so that input values are not expanded.
+	escaped := map[string]string{}
+	for k, v := range data {
+		escaped[k] = strings.ReplaceAll(v, "$", "$$")
+	}
+	t, err := template.New("value").Option("missingkey=zero").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	sb := &strings.Builder{}
+	if err := t.Execute(sb, escaped); err != nil {
+		return "", err
+	}
+	return os.Expand(sb.String(), func(s string) string {
+		if s == "$" {
+			return "$"
+		}
+		return envs[s]
+	}), nil
+}
+
+// inputValues returns the plugin inputs from the PLUGIN_
+// prefixed environment variables. The input names are
+// trimmed of the prefix and converted to lowercase.
+func inputValues(envs map[string]string) map[string]string {
+	out := map[string]string{}
+	for k, v := range envs {
+		if strings.HasPrefix(k, "PLUGIN_") {
+			out[strings.ToLower(strings.TrimPrefix(k, "PLUGIN_"))] = v
+		}
+	}
+	return out
+}
+
+// helper function quotes the argument for use in a
+// powershell command string.
+func quotePwsh(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
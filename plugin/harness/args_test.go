@@ -0,0 +1,81 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandArgs(t *testing.T) {
+	env := []string{
+		"HOME=/root",
+		"PLUGIN_URL=http://example.com",
+		"PLUGIN_LOG_LEVEL=debug",
+		"PLUGIN_PASSWORD=pa$$word",
+		"PLUGIN_SECRET=a{{b",
+		"PLUGIN_INJECT={{ .password }}",
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "plain",
+			args:     []string{"deploy", "--force"},
+			expected: []string{"deploy", "--force"},
+		},
+		{
+			name:     "environment variables",
+			args:     []string{"--home=$HOME", "--url=${PLUGIN_URL}"},
+			expected: []string{"--home=/root", "--url=http://example.com"},
+		},
+		{
+			name:     "template inputs",
+			args:     []string{"--url={{ .url }}", "{{ if eq .log_level \"debug\" }}-v{{ end }}"},
+			expected: []string{"--url=http://example.com", "-v"},
+		},
+		{
+			name:     "missing input",
+			args:     []string{"--token={{ .token }}", "--missing=$MISSING"},
+			expected: []string{"--token=", "--missing="},
+		},
+		{
+			name:     "input values are not parsed as a template",
+			args:     []string{"--secret=${PLUGIN_SECRET}", "--inject=$PLUGIN_INJECT", "--secret={{ .secret }}"},
+			expected: []string{"--secret=a{{b", "--inject={{ .password }}", "--secret=a{{b"},
+		},
+		{
+			name:     "input values are not expanded",
+			args:     []string{"--password={{ .password }}", "--escaped=$$HOME"},
+			expected: []string{"--password=pa$$word", "--escaped=$HOME"},
+		},
+		{
+			name:    "invalid template",
+			args:    []string{"{{ .url "},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandArgs(tt.args, env)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestQuotePwsh(t *testing.T) {
+	assert.Equal(t, "'hello world'", quotePwsh("hello world"))
+	assert.Equal(t, "'it''s'", quotePwsh("it's"))
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/drone/plugin/plugin/internal/file"
+	"golang.org/x/exp/slog"
+)
+
+// PlatformError is returned when the plugin does not provide
+// a binary source for the host platform.
+type PlatformError struct {
+	Platform  string
+	Supported []string
+}
+
+// Error implements the error interface.
+func (e *PlatformError) Error() string {
+	return fmt.Sprintf("no binary source for platform %s, supported platforms: %s",
+		e.Platform, strings.Join(e.Supported, ", "))
+}
+
+// matchPlatform returns the binary source that best matches
+// the os and arch. Exact matches take precedence over os
+// wildcards (linux/*), which take precedence over arch
+// wildcards (*/amd64).
+func matchPlatform(platforms map[string]*BinarySource, goos, goarch string) (*BinarySource, error) {
+	var match *BinarySource
+	var score int
+	for _, key := range platformKeys(platforms) {
+		source := platforms[key]
+		if source == nil || source.Source == "" {
+			continue
+		}
+		os_, arch, ok := strings.Cut(key, "/")
+		if !ok {
+			arch = "*"
+		}
+		if (os_ != "*" && os_ != goos) || (arch != "*" && arch != goarch) {
+			continue
+		}
+
+		// score the match so that more specific platforms
+		// take precedence over wildcard platforms.
+		s := 1
+		if os_ != "*" {
+			s += 2
+		}
+		if arch != "*" {
+			s++
+		}
+		if s > score {
+			match, score = source, s
+		}
+	}
+	if match == nil {
+		return nil, &PlatformError{
+			Platform:  goos + "/" + goarch,
+			Supported: platformKeys(platforms),
+		}
+	}
+	return match, nil
+}
+
+// verifyChecksum returns an error if the sha256 checksum of
+// the file does not match the expected checksum. The checksum
+// may optionally be prefixed with the algorithm (sha256:).
+func verifyChecksum(path, checksum string) error {
+	algo, want, ok := strings.Cut(checksum, ":")
+	if !ok {
+		algo, want = "sha256", checksum
+	}
+	if algo != "sha256" {
+		return fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+
+	got, err := sha256File(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, strings.TrimSpace(want)) {
+		return fmt.Errorf("checksum mismatch: want %s, got %s", want, got)
+	}
+	return nil
+}
+
+// Digest downloads the binary from the url and returns the
+// sha256 digest, prefixed with the algorithm (sha256:). The
+// digest is computed after the binary is decompressed.
+func Digest(url string) (string, error) {
+	binpath, err := file.Download(url)
+	if err != nil {
+		return "", err
+	}
+	sum, err := sha256File(binpath)
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + sum, nil
+}
+
+// BinarySources returns the binary source urls of the plugin
+// in the source directory, with the source templates expanded.
+// The urls include the binary sources of the run blocks and
+// the pre and post hooks, for the host platform and for each
+// platform declared by the binary sources, so that the plugin
+// can be locked once and executed on every platform.
+func BinarySources(source, ref, sha string, environ []string) ([]string, error) {
+	out, err := parseFile(filepath.Join(source, "plugin.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*Run
+	for _, run := range out.Run {
+		if run != nil {
+			runs = append(runs, run)
+		}
+	}
+	for _, hooks := range []HookList{out.Pre, out.Post} {
+		for _, hook := range hooks {
+			if hook != nil {
+				runs = append(runs, &hook.Run)
+			}
+		}
+	}
+
+	// the host platform is followed by the platforms
+	// declared by the binary sources. wildcard platforms
+	// are not expanded.
+	targets := []string{runtime.GOOS + "/" + runtime.GOARCH}
+	for _, run := range runs {
+		for _, key := range platformKeys(run.Binary.Platforms) {
+			if strings.Contains(key, "/") && !strings.Contains(key, "*") {
+				targets = append(targets, key)
+			}
+		}
+	}
+
+	var urls []string
+	seen := map[string]bool{}
+	for _, target := range targets {
+		goos, goarch, _ := strings.Cut(target, "/")
+		for _, run := range runs {
+			if !run.When.matchTarget(goos, goarch) {
+				continue
+			}
+			sources, err := getBinarySources(nil, run.Binary, goos, goarch)
+			if err != nil {
+				slog.Debug("no binary source", slog.String("platform", target), "error", err)
+				continue
+			}
+			for _, source := range sources {
+				url, err := NewMetadata(source.Source, ref, sha, environ).forPlatform(goos, goarch).Generate()
+				if err != nil {
+					return nil, err
+				}
+				if !seen[url] {
+					seen[url] = true
+					urls = append(urls, url)
+				}
+			}
+		}
+	}
+	return urls, nil
+}
+
+// helper function returns the hex encoded sha256 checksum of
+// the file.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// helper function returns the sorted platform keys.
+func platformKeys(platforms map[string]*BinarySource) []string {
+	var keys []string
+	for key := range platforms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/drone/plugin/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPlatform(t *testing.T) {
+	platforms := map[string]*BinarySource{
+		"linux/amd64": {Source: "linux-amd64"},
+		"linux/*":     {Source: "linux"},
+		"*/arm64":     {Source: "arm64"},
+		"windows":     {Source: "windows"},
+		"*/*":         {Source: "any"},
+	}
+	tests := []struct {
+		os, arch, expected string
+	}{
+		{"linux", "amd64", "linux-amd64"},
+		{"linux", "arm64", "linux"},
+		{"darwin", "arm64", "arm64"},
+		{"windows", "amd64", "windows"},
+		{"freebsd", "386", "any"},
+	}
+	for _, tt := range tests {
+		got, err := matchPlatform(platforms, tt.os, tt.arch)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expected, got.Source, "%s/%s", tt.os, tt.arch)
+		}
+	}
+
+	_, err := matchPlatform(map[string]*BinarySource{"linux/amd64": {Source: "linux"}}, "darwin", "arm64")
+	assert.EqualError(t, err, "no binary source for platform darwin/arm64, supported platforms: linux/amd64")
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "step.exe")
+	if err := os.WriteFile(path, []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	assert.NoError(t, verifyChecksum(path, sum))
+	assert.NoError(t, verifyChecksum(path, "sha256:"+sum))
+	assert.Error(t, verifyChecksum(path, "sha256:0000"))
+	assert.Error(t, verifyChecksum(path, "md5:"+sum))
+}
+
+func TestDigest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(404)
+			w.Write([]byte("not found"))
+			return
+		}
+		w.Write([]byte("foo"))
+	}))
+	defer ts.Close()
+
+	digest, err := Digest(ts.URL + "/plugin")
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", digest)
+
+	// error pages are not hashed or cached.
+	_, err = Digest(ts.URL + "/missing")
+	assert.ErrorContains(t, err, "unexpected response status: 404 Not Found")
+	assert.NoFileExists(t, filepath.Join(cache.GetKeyName(ts.URL+"/missing"), "step.exe"))
+}
+
+func TestDownloadBinary_Checksums(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	}))
+	defer ts.Close()
+
+	sum := "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	e := &Execer{
+		Checksums: map[string]string{
+			ts.URL + "/locked":   sum,
+			ts.URL + "/mismatch": "sha256:0000",
+		},
+	}
+
+	_, err := e.downloadBinary(&BinarySource{Source: ts.URL + "/locked"})
+	assert.NoError(t, err)
+
+	_, err = e.downloadBinary(&BinarySource{Source: ts.URL + "/mismatch"})
+	assert.Error(t, err)
+
+	// binaries that are not in the lockfile are rejected,
+	// even if the plugin provides a checksum.
+	_, err = e.downloadBinary(&BinarySource{Source: ts.URL + "/unlocked", Checksum: sum})
+	assert.Error(t, err)
+}
+
+func TestBinarySources(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plugin.yml"), []byte(`
+run:
+  binary:
+    source: https://example.com/plugin-{{ os }}-{{ arch }}
+    fallback_source: https://example.com/plugin
+pre:
+- binary:
+    source: https://example.com/plugin
+post:
+- when:
+    os: plan9
+  binary:
+    source: https://example.com/post
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := BinarySources(dir, "", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://example.com/plugin-" + runtime.GOOS + "-" + runtime.GOARCH,
+		"https://example.com/plugin",
+	}, urls)
+}
+
+func TestBinarySources_Platforms(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plugin.yml"), []byte(`
+run:
+- when:
+    os: [plan9]
+  bash:
+    path: run.sh
+- binary:
+    platforms:
+      linux/amd64: https://example.com/plugin-linux-amd64
+      windows/amd64: https://example.com/plugin-{{ os }}-{{ arch }}{{ ext }}
+      darwin/*: https://example.com/plugin-darwin
+post:
+- when:
+    os: [windows]
+  binary:
+    source: https://example.com/post-{{ os }}{{ ext }}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := BinarySources(dir, "", "", nil)
+	assert.NoError(t, err)
+
+	// the sources are locked for every declared platform,
+	// in addition to the host platform.
+	assert.Subset(t, urls, []string{
+		"https://example.com/plugin-linux-amd64",
+		"https://example.com/plugin-windows-amd64.exe",
+		"https://example.com/post-windows.exe",
+	})
+	if runtime.GOOS == "darwin" {
+		assert.Contains(t, urls, "https://example.com/plugin-darwin")
+	} else {
+		assert.NotContains(t, urls, "https://example.com/plugin-darwin")
+	}
+}
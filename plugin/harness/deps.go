@@ -0,0 +1,466 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/drone/plugin/plugin/internal/process"
+	"golang.org/x/exp/slog"
+)
+
+// DepsError is returned in strict mode when one or more
+// plugin dependencies fail to install.
+type DepsError struct {
+	Failed []string
+}
+
+// Error implements the error interface.
+func (e *DepsError) Error() string {
+	return fmt.Sprintf("failed to install dependencies: %s", strings.Join(e.Failed, ", "))
+}
+
+// manager defines a package manager used to install plugin
+// dependencies.
+type manager struct {
+	name string
+
+	// bin is the package manager executable, used to
+	// determine if the package manager is available.
+	bin string
+
+	// env provides additional environment variables used
+	// to run the package manager non-interactively.
+	env []string
+
+	// update returns the command used to refresh the
+	// package index. The update runs at most once, and
+	// only if one or more packages need to be installed.
+	update func() []string
+
+	// query returns the command used to determine if the
+	// package is installed with an optional version.
+	query func(name, version string) []string
+
+	// installed returns true if the query command output
+	// indicates the package is installed with a matching
+	// version.
+	installed func(out, name, version string) bool
+
+	// install returns the command used to install the
+	// package with an optional version.
+	install func(name, version string) []string
+}
+
+// apt returns the apt package manager.
+func apt() *manager {
+	return &manager{
+		name: "apt",
+		bin:  "apt-get",
+		env:  []string{"DEBIAN_FRONTEND=noninteractive"},
+		update: func() []string {
+			return sudo("apt-get", "update")
+		},
+		query: func(name, _ string) []string {
+			return []string{"dpkg-query", "-W", "-f=${Status} ${Version}", name}
+		},
+		installed: func(out, _, version string) bool {
+			fields := strings.Fields(out)
+			if len(fields) < 4 || fields[2] != "installed" {
+				return false
+			}
+			return matchVersion(fields[3], version)
+		},
+		install: func(name, version string) []string {
+			if version != "" {
+				name = name + "=" + version
+			}
+			return sudo("apt-get", "install", "-y", name)
+		},
+	}
+}
+
+// brew returns the homebrew package manager. Versions are
+// installed and queried using versioned formulae (e.g.
+// python@3.11).
+func brew() *manager {
+	formula := func(name, version string) string {
+		if version != "" {
+			return name + "@" + version
+		}
+		return name
+	}
+	return &manager{
+		name: "brew",
+		bin:  "brew",
+		env:  []string{"NONINTERACTIVE=1", "HOMEBREW_NO_AUTO_UPDATE=1"},
+		query: func(name, version string) []string {
+			return []string{"brew", "list", "--versions", formula(name, version)}
+		},
+		installed: func(out, _, _ string) bool {
+			return strings.TrimSpace(out) != ""
+		},
+		install: func(name, version string) []string {
+			return []string{"brew", "install", formula(name, version)}
+		},
+	}
+}
+
+// choco returns the chocolatey package manager.
+func choco() *manager {
+	return &manager{
+		name: "choco",
+		bin:  "choco",
+		query: func(name, _ string) []string {
+			return []string{"choco", "list", "--exact", "--limit-output", name}
+		},
+		installed: func(out, name, version string) bool {
+			for _, line := range strings.Split(out, "\n") {
+				parts := strings.SplitN(strings.TrimSpace(line), "|", 2)
+				if len(parts) == 2 && strings.EqualFold(parts[0], name) {
+					return matchVersion(parts[1], version)
+				}
+			}
+			return false
+		},
+		install: func(name, version string) []string {
+			args := []string{"choco", "install", name, "-y", "--no-progress"}
+			if version != "" {
+				args = append(args, "--version", version)
+			}
+			return args
+		},
+	}
+}
+
+// apk returns the alpine package manager.
+func apk() *manager {
+	return &manager{
+		name: "apk",
+		bin:  "apk",
+		query: func(name, _ string) []string {
+			return []string{"apk", "list", "--installed", name}
+		},
+		installed: func(out, name, version string) bool {
+			// the output format is name-version-release arch {origin} ...
+			for _, line := range strings.Split(out, "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 0 || !strings.HasPrefix(fields[0], name+"-") {
+					continue
+				}
+				return matchVersion(strings.TrimPrefix(fields[0], name+"-"), version)
+			}
+			return false
+		},
+		install: func(name, version string) []string {
+			if version != "" {
+				name = name + "=" + version
+			}
+			return sudo("apk", "add", "--no-cache", name)
+		},
+	}
+}
+
+// dnf returns the dnf package manager, falling back to yum
+// on hosts where dnf is not available.
+func dnf() *manager {
+	bin := "dnf"
+	if _, err := exec.LookPath(bin); err != nil {
+		bin = "yum"
+	}
+	return &manager{
+		name: bin,
+		bin:  bin,
+		query: func(name, _ string) []string {
+			return []string{"rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}", name}
+		},
+		installed: func(out, _, version string) bool {
+			return matchVersion(strings.TrimSpace(out), version)
+		},
+		install: func(name, version string) []string {
+			if version != "" {
+				name = name + "-" + version
+			}
+			return sudo(bin, "install", "-y", name)
+		},
+	}
+}
+
+// pip returns the python package manager.
+func pip() *manager {
+	bin := "pip3"
+	if _, err := exec.LookPath(bin); err != nil {
+		bin = "pip"
+	}
+	return &manager{
+		name: "pip",
+		bin:  bin,
+		env:  []string{"PIP_DISABLE_PIP_VERSION_CHECK=1", "PIP_NO_INPUT=1"},
+		query: func(name, _ string) []string {
+			return []string{bin, "show", name}
+		},
+		installed: func(out, _, version string) bool {
+			for _, line := range strings.Split(out, "\n") {
+				if strings.HasPrefix(line, "Version:") {
+					return matchVersion(strings.TrimSpace(strings.TrimPrefix(line, "Version:")), version)
+				}
+			}
+			return false
+		},
+		install: func(name, version string) []string {
+			if version != "" {
+				name = name + "==" + version
+			}
+			return []string{bin, "install", name}
+		},
+	}
+}
+
+// npm returns the node package manager. Packages are
+// installed globally.
+func npm() *manager {
+	return &manager{
+		name: "npm",
+		bin:  "npm",
+		env:  []string{"NPM_CONFIG_FUND=false", "NPM_CONFIG_UPDATE_NOTIFIER=false"},
+		query: func(name, _ string) []string {
+			return []string{"npm", "ls", "--global", "--depth=0", "--json", name}
+		},
+		installed: func(out, name, version string) bool {
+			ls := struct {
+				Dependencies map[string]struct {
+					Version string `json:"version"`
+				} `json:"dependencies"`
+			}{}
+			if err := json.Unmarshal([]byte(out), &ls); err != nil {
+				return false
+			}
+			dep, ok := ls.Dependencies[name]
+			return ok && matchVersion(dep.Version, version)
+		},
+		install: func(name, version string) []string {
+			if version != "" {
+				name = name + "@" + version
+			}
+			return []string{"npm", "install", "--global", name}
+		},
+	}
+}
+
+// installDeps installs the plugin dependencies and returns
+// the list of dependencies that failed to install.
+func (e *Execer) installDeps(ctx context.Context, deps *Deps) []string {
+	var failed []string
+
+	// install system packages using the package manager
+	// available on the host.
+	m, unavailable := systemManager(deps)
+	failed = append(failed, unavailable...)
+	switch {
+	case m == nil:
+	case m.name == "apt":
+		failed = append(failed, e.installAptSources(ctx, deps.Apt.Sources)...)
+		failed = append(failed, e.installPackages(ctx, m, deps.Apt.Packages)...)
+	default:
+		failed = append(failed, e.installPackages(ctx, m, systemPackages(deps, m))...)
+	}
+
+	// install language packages.
+	if len(deps.Pip) != 0 {
+		failed = append(failed, e.installPackages(ctx, pip(), deps.Pip)...)
+	}
+	if len(deps.Npm) != 0 {
+		failed = append(failed, e.installPackages(ctx, npm(), deps.Npm)...)
+	}
+
+	if len(deps.Run) != 0 {
+		failed = append(failed, e.installRunScripts(ctx, deps.Run)...)
+	}
+	return failed
+}
+
+// systemManager returns the system package manager available
+// on the host, or nil if no system packages are declared. If
+// system packages are declared, but no declared package manager
+// is available, the declared packages are returned.
+func systemManager(deps *Deps) (*manager, []string) {
+	var candidates []*manager
+	switch runtime.GOOS {
+	case "linux":
+		candidates = []*manager{apt(), apk(), dnf()}
+	case "darwin":
+		candidates = []*manager{brew()}
+	case "windows":
+		candidates = []*manager{choco()}
+	}
+
+	var declared []string
+	var unavailable []string
+	for _, m := range candidates {
+		if len(systemPackages(deps, m)) == 0 && !(m.name == "apt" && len(deps.Apt.Sources) != 0) {
+			continue
+		}
+		declared = append(declared, m.name)
+		if _, err := exec.LookPath(m.bin); err == nil {
+			slog.Debug("selected package manager", slog.String("name", m.name))
+			return m, nil
+		}
+		for _, pkg := range systemPackages(deps, m) {
+			unavailable = append(unavailable, m.name+":"+pkg)
+		}
+		if m.name == "apt" {
+			for _, source := range deps.Apt.Sources {
+				if source != nil {
+					unavailable = append(unavailable, "apt-source:"+source.Data)
+				}
+			}
+		}
+	}
+	if len(declared) > 0 {
+		slog.Warn("no supported package manager found", slog.String("declared", strings.Join(declared, ", ")))
+	}
+	return nil, unavailable
+}
+
+// systemPackages returns the packages declared for the
+// system package manager.
+func systemPackages(deps *Deps, m *manager) []string {
+	switch m.name {
+	case "apt":
+		return deps.Apt.Packages
+	case "apk":
+		return deps.Apk
+	case "dnf", "yum":
+		return deps.Dnf
+	case "brew":
+		return deps.Brew
+	case "choco":
+		return deps.Choco
+	}
+	return nil
+}
+
+// installPackages installs the packages that are not already
+// installed and returns the packages that failed to install.
+func (e *Execer) installPackages(ctx context.Context, m *manager, pkgs []string) []string {
+	env := append(append([]string{}, e.Environ...), m.env...)
+
+	var pending []string
+	for _, pkg := range pkgs {
+		name, version := splitPin(pkg)
+		if name == "" {
+			continue
+		}
+		if m.query != nil {
+			out, err := e.output(ctx, env, m.query(name, version)...)
+			if err == nil && m.installed(out, name, version) {
+				slog.Debug(m.name+" package already installed", slog.String("package", pkg))
+				continue
+			}
+		}
+		pending = append(pending, pkg)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var failed []string
+	if m.update != nil {
+		slog.Debug(m.name + " update")
+		cmd := command(ctx, m.update())
+		if err := runCmds(ctx, []*exec.Cmd{cmd}, env, e.Workdir,
+			e.Stdout, e.Stderr); err != nil {
+			slog.Error(m.name+" update failed", "error", err)
+			failed = append(failed, m.name+":update")
+		}
+	}
+
+	for _, pkg := range pending {
+		slog.Debug(m.name+" install", slog.String("package", pkg))
+
+		name, version := splitPin(pkg)
+		cmd := command(ctx, m.install(name, version))
+		if err := runCmds(ctx, []*exec.Cmd{cmd}, env, e.Workdir,
+			e.Stdout, e.Stderr); err != nil {
+			slog.Error(m.name+" install failed", slog.String("package", pkg), "error", err)
+			failed = append(failed, m.name+":"+pkg)
+		}
+	}
+	return failed
+}
+
+func (e *Execer) installRunScripts(ctx context.Context, cmds []string) []string {
+	shell := "bash"
+	if _, err := exec.LookPath("bash"); err != nil {
+		shell = "sh"
+	}
+	var failed []string
+	for _, item := range cmds {
+		cmd := process.Command(ctx, shell, "-c", item)
+		if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir,
+			e.Stdout, e.Stderr); err != nil {
+			slog.Error("run command failed", slog.String("command", item), "error", err)
+			failed = append(failed, "run:"+item)
+		}
+	}
+	return failed
+}
+
+// output runs the command and returns the standard output.
+func (e *Execer) output(ctx context.Context, env []string, args ...string) (string, error) {
+	cmd := command(ctx, args)
+	cmd.Env = env
+	cmd.Dir = e.Workdir
+	trace(ctx, cmd)
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// helper function splits the package into name and version.
+func splitPin(pkg string) (name, version string) {
+	pkg = strings.TrimSpace(pkg)
+	if parts := strings.SplitN(pkg, "=", 2); len(parts) == 2 {
+		// trim the second equal sign to support the
+		// pip name==version syntax.
+		return parts[0], strings.TrimPrefix(parts[1], "=")
+	}
+	return pkg, ""
+}
+
+// helper function returns true if the installed version
+// matches the pinned version. A pinned version with a
+// trailing wildcard matches by prefix.
+func matchVersion(installed, version string) bool {
+	if version == "" {
+		return true
+	}
+	if strings.HasSuffix(version, "*") {
+		return strings.HasPrefix(installed, strings.TrimSuffix(version, "*"))
+	}
+	return installed == version
+}
+
+// helper function prefixes the command with sudo if the
+// process is not running as root and sudo is available.
+func sudo(args ...string) []string {
+	if os.Geteuid() == 0 {
+		return args
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return args
+	}
+	return append([]string{"sudo"}, args...)
+}
+
+// helper function creates a command from the argument list.
+func command(ctx context.Context, args []string) *exec.Cmd {
+	return process.Command(ctx, args[0], args[1:]...)
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPin(t *testing.T) {
+	tests := []struct {
+		pkg, name, version string
+	}{
+		{"curl", "curl", ""},
+		{"curl=7.81.0-1ubuntu1", "curl", "7.81.0-1ubuntu1"},
+		{" jq=1.6* ", "jq", "1.6*"},
+		{"requests==2.31.0", "requests", "2.31.0"},
+	}
+	for _, tt := range tests {
+		name, version := splitPin(tt.pkg)
+		assert.Equal(t, tt.name, name)
+		assert.Equal(t, tt.version, version)
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	assert.True(t, matchVersion("1.6-2.1", ""))
+	assert.True(t, matchVersion("1.6-2.1", "1.6-2.1"))
+	assert.True(t, matchVersion("1.6-2.1", "1.6*"))
+	assert.False(t, matchVersion("1.6-2.1", "1.7*"))
+	assert.False(t, matchVersion("1.6-2.1", "1.6"))
+}
+
+func TestManagerInstalled(t *testing.T) {
+	assert.True(t, apt().installed("install ok installed 1.6-2.1", "jq", ""))
+	assert.True(t, apt().installed("install ok installed 1.6-2.1", "jq", "1.6*"))
+	assert.False(t, apt().installed("install ok installed 1.6-2.1", "jq", "1.7*"))
+	assert.False(t, apt().installed("deinstall ok config-files 1.6-2.1", "jq", ""))
+	assert.False(t, apt().installed("", "jq", ""))
+
+	assert.True(t, brew().installed("jq 1.6\n", "jq", ""))
+	assert.False(t, brew().installed("", "jq", ""))
+
+	assert.True(t, choco().installed("git|2.40.0\n", "git", ""))
+	assert.True(t, choco().installed("git|2.40.0\n", "git", "2.40.0"))
+	assert.False(t, choco().installed("git|2.40.0\n", "git", "2.41.0"))
+	assert.False(t, choco().installed("", "git", ""))
+
+	assert.True(t, apk().installed("curl-8.5.0-r0 x86_64 {curl} (curl) [installed]\n", "curl", ""))
+	assert.True(t, apk().installed("curl-8.5.0-r0 x86_64 {curl} (curl) [installed]\n", "curl", "8.5*"))
+	assert.False(t, apk().installed("curl-8.5.0-r0 x86_64 {curl} (curl) [installed]\n", "curl", "8.6*"))
+	assert.False(t, apk().installed("", "curl", ""))
+
+	assert.True(t, dnf().installed("7.76.1-26.el9", "curl", ""))
+	assert.True(t, dnf().installed("7.76.1-26.el9", "curl", "7.76.1-26.el9"))
+	assert.False(t, dnf().installed("7.76.1-26.el9", "curl", "7.61*"))
+
+	assert.True(t, pip().installed("Name: requests\nVersion: 2.31.0\n", "requests", ""))
+	assert.True(t, pip().installed("Name: requests\nVersion: 2.31.0\n", "requests", "2.31.0"))
+	assert.False(t, pip().installed("Name: requests\nVersion: 2.31.0\n", "requests", "2.30.0"))
+	assert.False(t, pip().installed("", "requests", ""))
+
+	assert.True(t, npm().installed(`{"dependencies": {"yarn": {"version": "1.22.19"}}}`, "yarn", ""))
+	assert.True(t, npm().installed(`{"dependencies": {"yarn": {"version": "1.22.19"}}}`, "yarn", "1.22.19"))
+	assert.False(t, npm().installed(`{"dependencies": {"yarn": {"version": "1.22.19"}}}`, "yarn", "2*"))
+	assert.False(t, npm().installed(`{}`, "yarn", ""))
+}
+
+func TestManagerInstall(t *testing.T) {
+	assert.Equal(t, []string{"choco", "install", "git", "-y", "--no-progress", "--version", "2.40.0"},
+		choco().install("git", "2.40.0"))
+	assert.Equal(t, []string{"brew", "install", "python@3.11"}, brew().install("python", "3.11"))
+	assert.Equal(t, []string{"brew", "list", "--versions", "python@3.11"}, brew().query("python", "3.11"))
+	assert.Equal(t, "requests==2.31.0", last(pip().install("requests", "2.31.0")))
+	assert.Equal(t, "yarn@1.22.19", last(npm().install("yarn", "1.22.19")))
+	assert.Equal(t, "curl=8.5.0-r0", last(apk().install("curl", "8.5.0-r0")))
+	assert.Equal(t, "curl-7.76.1", last(dnf().install("curl", "7.76.1")))
+}
+
+func TestSystemPackages(t *testing.T) {
+	deps := &Deps{
+		Apt:   Apt{Packages: []string{"curl"}},
+		Apk:   []string{"curl", "bash"},
+		Dnf:   []string{"curl-minimal"},
+		Brew:  []string{"jq"},
+		Choco: []string{"git"},
+	}
+	assert.Equal(t, []string{"curl"}, systemPackages(deps, apt()))
+	assert.Equal(t, []string{"curl", "bash"}, systemPackages(deps, apk()))
+	assert.Equal(t, []string{"curl-minimal"}, systemPackages(deps, dnf()))
+	assert.Equal(t, []string{"jq"}, systemPackages(deps, brew()))
+	assert.Equal(t, []string{"git"}, systemPackages(deps, choco()))
+}
+
+func TestSystemManagerUnavailable(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux package managers required")
+	}
+	t.Setenv("PATH", t.TempDir())
+
+	deps := &Deps{
+		Apt: Apt{Packages: []string{"jq"}},
+		Apk: []string{"curl"},
+	}
+	m, unavailable := systemManager(deps)
+	assert.Nil(t, m)
+	assert.Equal(t, []string{"apt:jq", "apk:curl"}, unavailable)
+
+	// no packages are reported if none are declared.
+	m, unavailable = systemManager(&Deps{Pip: []string{"requests"}})
+	assert.Nil(t, m)
+	assert.Empty(t, unavailable)
+}
+
+func last(s []string) string {
+	return s[len(s)-1]
+}
+
+func TestInstallPackages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell required")
+	}
+
+	var installed []string
+	m := &manager{
+		name: "fake",
+		update: func() []string {
+			return []string{"sh", "-c", "exit 1"}
+		},
+		query: func(name, _ string) []string {
+			return []string{"sh", "-c", "echo " + name}
+		},
+		installed: func(out, name, _ string) bool {
+			return name == "present"
+		},
+		install: func(name, _ string) []string {
+			installed = append(installed, name)
+			if name == "broken" {
+				return []string{"sh", "-c", "exit 1"}
+			}
+			return []string{"sh", "-c", "exit 0"}
+		},
+	}
+
+	e := &Execer{Stdout: io.Discard, Stderr: io.Discard}
+	failed := e.installPackages(context.Background(), m, []string{"present", "missing", "broken=1.0"})
+	assert.Equal(t, []string{"missing", "broken"}, installed)
+	assert.Equal(t, []string{"fake:update", "fake:broken=1.0"}, failed)
+}
+
+func TestDepsError(t *testing.T) {
+	err := &DepsError{Failed: []string{"apt:jq", "run:make setup"}}
+	assert.EqualError(t, err, "failed to install dependencies: apt:jq, run:make setup")
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/drone/plugin/plugin/internal/environ"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slog"
+)
+
+// pluginEnviron returns the plugin environment with the input
+// rename rules and the environment block applied. Inputs that
+// declare an env name are also exported with that name. The
+// environment block values expand environment variables and
+// are executed as templates with the plugin inputs provided
+// as template data, for example:
+//
+//	env:
+//	  GITHUB_TOKEN: "{{ .token }}"
+//	  GITHUB_REPOSITORY: ${DRONE_REPO}
+//
+// The names of the environment variables added to the plugin
+// environment are also returned.
+func pluginEnviron(inputs []*Input, block map[string]string, env []string) ([]string, []string, error) {
+	renames := false
+	for _, in := range inputs {
+		if in != nil && in.Env != "" {
+			renames = true
+		}
+	}
+	if !renames && len(block) == 0 {
+		return env, nil, nil
+	}
+
+	envs := environ.Map(env)
+	out := environ.Map(env)
+	added := map[string]bool{}
+	for _, in := range inputs {
+		if in == nil || in.Name == "" || in.Env == "" {
+			continue
+		}
+		if val, ok := envs[inputKey(in.Name)]; ok {
+			slog.Debug("input env", slog.String("name", in.Name), slog.String("env", in.Env))
+			out[in.Env] = val
+			added[in.Env] = true
+		}
+	}
+
+	// the environment block is rendered against the input
+	// environment, and takes precedence over renamed inputs.
+	data := inputValues(envs)
+	var keys []string
+	for key := range block {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		val, err := expand(block[key], envs, data)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, fmt.Sprintf("invalid env: %s", key))
+		}
+		slog.Debug("env", slog.String("name", key))
+		out[key] = val
+		added[key] = true
+	}
+
+	var names []string
+	for name := range added {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return environ.Slice(out), names, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginEnviron(t *testing.T) {
+	inputs := []*Input{
+		{Name: "token", Env: "GITHUB_TOKEN"},
+		{Name: "api-url", Env: "GITHUB_API_URL"},
+		{Name: "debug"},
+	}
+	block := map[string]string{
+		"GITHUB_REPOSITORY": "${DRONE_REPO}",
+		"GH_HOST":           "{{ .api_url }}",
+		"GITHUB_TOKEN":      "token {{ .token }}",
+		"STATIC":            "value",
+	}
+	env := []string{
+		"DRONE_REPO=octocat/hello-world",
+		"PLUGIN_TOKEN=secret",
+		"PLUGIN_API_URL=https://github.example.com",
+	}
+	expected := []string{
+		"DRONE_REPO=octocat/hello-world",
+		"GH_HOST=https://github.example.com",
+		"GITHUB_API_URL=https://github.example.com",
+		"GITHUB_REPOSITORY=octocat/hello-world",
+		"GITHUB_TOKEN=token secret",
+		"PLUGIN_API_URL=https://github.example.com",
+		"PLUGIN_TOKEN=secret",
+		"STATIC=value",
+	}
+	got, added, err := pluginEnviron(inputs, block, env)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.Equal(t, []string{"GH_HOST", "GITHUB_API_URL", "GITHUB_REPOSITORY", "GITHUB_TOKEN", "STATIC"}, added)
+
+	// the environment is unchanged if there are no rename
+	// rules and no environment block.
+	got, added, err = pluginEnviron([]*Input{{Name: "token"}}, nil, env)
+	assert.NoError(t, err)
+	assert.Equal(t, env, got)
+	assert.Empty(t, added)
+
+	// input values are not parsed as a template.
+	got, _, err = pluginEnviron(nil, map[string]string{
+		"PASSWORD": "${PLUGIN_PASSWORD}",
+		"SECRET":   "{{ .secret }}",
+	}, []string{
+		"PLUGIN_PASSWORD={{ .token }}",
+		"PLUGIN_SECRET=a{{b",
+		"PLUGIN_TOKEN=secret",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, got, "PASSWORD={{ .token }}")
+	assert.Contains(t, got, "SECRET=a{{b")
+
+	_, _, err = pluginEnviron(nil, map[string]string{"INVALID": "{{ .token"}, env)
+	assert.EqualError(t, err, "invalid env: INVALID: template: value:1: unclosed action")
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
@@ -15,26 +16,58 @@ import (
 	"time"
 
 	"github.com/drone/plugin/cache"
+	"github.com/drone/plugin/plugin/docker"
 	"github.com/drone/plugin/plugin/internal/file"
+	"github.com/drone/plugin/plugin/internal/process"
 	"github.com/drone/plugin/utils"
+	"github.com/pkg/errors"
 	"golang.org/x/exp/slog"
 )
 
 // Execer executes a harness plugin.
 type Execer struct {
 	Ref           string // Git ref for source code
+	Sha           string // Git commit for source code
 	Source        string // plugin source code directory
 	Workdir       string // pipeline working directory (aka workspace)
 	DownloadOnly  bool
 	BinarySources utils.CustomStringSliceFlag
 	DisableClone  bool
+	StrictDeps    bool   // fail the step if a dependency fails to install
+	DockerHost    string // docker engine api host (defaults to DOCKER_HOST)
 	Environ       []string
 	Stdout        io.Writer
 	Stderr        io.Writer
+
+	Timeout      time.Duration // overall step timeout
+	DepsTimeout  time.Duration // dependency installation timeout
+	BuildTimeout time.Duration // plugin build timeout
+	RunTimeout   time.Duration // plugin execution timeout
+	GracePeriod  time.Duration // time to wait for the plugin to exit after it is signaled
+
+	OutputFile       string // step output file (aka DRONE_OUTPUT)
+	SecretOutputFile string // step secret output file
+
+	// Checksums provides the locked binary checksums keyed by
+	// url. If not nil, binaries without a locked checksum are
+	// not executed.
+	Checksums map[string]string
+
+	outputPath string   // plugin output file
+	pluginEnv  []string // environment variables added by the plugin
+	cleanups   []func() // cleanup functions run after execution
 }
 
-// Exec executes a bitrise plugin.
+// Exec executes a harness plugin.
 func (e *Execer) Exec(ctx context.Context) error {
+	if e.GracePeriod > 0 {
+		ctx = process.WithGracePeriod(ctx, e.GracePeriod)
+	}
+	ctx, done := process.WithTimeout(ctx, "step", e.Timeout)
+	return done(e.execPlugin(ctx))
+}
+
+func (e *Execer) execPlugin(ctx context.Context) error {
 	if !e.DisableClone {
 		// parse the bitrise plugin yaml
 		out, err := parseFile(filepath.Join(e.Source, "plugin.yml"))
@@ -42,38 +75,127 @@ func (e *Execer) Exec(ctx context.Context) error {
 			return err
 		}
 
-		// install dependencies
-		if runtime.GOOS == "linux" {
-			e.installAptDeps(ctx, out.Deps.Apt.Packages, out.Deps.Apt.Sources)
-		} else if runtime.GOOS == "darwin" {
-			e.installBrewDeps(ctx, out.Deps.Brew)
-		} else if runtime.GOOS == "windows" {
-			e.installChocoDeps(ctx, out.Deps.Choco)
+		// validate the plugin inputs and apply defaults
+		// before installing dependencies, so that the step
+		// fails fast on invalid configuration.
+		e.Environ, err = validateInputs(out.Inputs, e.Environ)
+		if err != nil {
+			return err
 		}
 
-		if len(out.Deps.Run) != 0 {
-			e.installRunScripts(ctx, out.Deps.Run)
+		// apply the input rename rules and the plugin
+		// environment block.
+		e.Environ, e.pluginEnv, err = pluginEnviron(out.Inputs, out.Env, e.Environ)
+		if err != nil {
+			return err
 		}
 
-		// execute the plugin. thxe execution logic differs
-		// based on programming language.
-		sources := e.getBinarySources(out.Run.Binary.Source, out.Run.Binary.FallbackSource)
-		if len(sources) > 0 {
-			return e.runSourceExecutable(ctx, sources)
-		} else if module := out.Run.Go.Module; module != "" {
-			return e.runGoExecutable(ctx, module)
-		} else {
-			return e.runShellExecutable(ctx, out)
+		// select the deps and run blocks with conditions
+		// that match the host platform.
+		host := e.hostPlatform()
+		deps := selectDeps(out.Deps, host)
+		run, err := selectRun(out.Run, host)
+		if err != nil {
+			return err
+		}
+
+		defer e.cleanup()
+
+		// install dependencies. dependency failures are
+		// logged and ignored unless strict mode is enabled.
+		depsCtx, depsDone := process.WithTimeout(ctx, "deps", e.DepsTimeout)
+		failed := e.installDeps(depsCtx, deps)
+		if err := depsDone(depsCtx.Err()); err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			if deps.Strict || e.StrictDeps {
+				return &DepsError{Failed: failed}
+			}
+			slog.Warn("failed to install dependencies", slog.String("failed", strings.Join(failed, ", ")))
+		}
+
+		// provide the plugin a file to write declared
+		// outputs, which are forwarded to the step output
+		// file once the plugin completes.
+		if len(out.Outputs) > 0 && !e.DownloadOnly {
+			cleanup, err := e.createOutputFile()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+		}
+
+		// the plugin runs if the pre hooks succeed, and the
+		// post hooks run based on the step status.
+		err = e.runPreHooks(ctx, out.Pre)
+		if err == nil {
+			err = e.run(ctx, run)
+		}
+		err = e.runPostHooks(ctx, out.Post, err)
+
+		// outputs are saved if the plugin succeeds, or if the
+		// plugin is terminated, in which case the outputs
+		// written before termination are flushed.
+		if e.outputPath != "" && (err == nil || ctx.Err() != nil) {
+			if serr := e.saveOutputs(out.Outputs); serr != nil {
+				if err != nil {
+					slog.Error("failed to save outputs", "error", serr)
+				} else {
+					err = serr
+				}
+			}
 		}
+		return err
 	} else if len(e.BinarySources.GetValue()) > 0 {
-		return e.runSourceExecutable(ctx, e.BinarySources.GetValue())
+		sources, err := getBinarySources(e.BinarySources.GetValue(), Binary{}, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			return err
+		}
+		return e.runSourceExecutable(ctx, sources)
 	} else {
 		slog.Error("clone is disabled and binary sources are empty. Aborting")
 		return nil
 	}
 }
 
-func (e *Execer) runSourceExecutable(ctx context.Context, sources []string) error {
+// cleanup runs the cleanup functions in reverse order.
+func (e *Execer) cleanup() {
+	for i := len(e.cleanups) - 1; i >= 0; i-- {
+		e.cleanups[i]()
+	}
+	e.cleanups = nil
+}
+
+// run executes the plugin. The execution logic differs
+// based on programming language.
+func (e *Execer) run(ctx context.Context, run *Run) error {
+	sources, err := getBinarySources(e.BinarySources.GetValue(), run.Binary, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	return e.runBlock(ctx, run, sources)
+}
+
+// runBlock executes the run block using the binary sources,
+// if provided.
+func (e *Execer) runBlock(ctx context.Context, run *Run, sources []*BinarySource) error {
+	if len(sources) > 0 {
+		return e.runSourceExecutable(ctx, sources)
+	} else if run.Go.Module != "" {
+		return e.runGoExecutable(ctx, &run.Go)
+	} else if run.Node.Entry != "" {
+		return e.runNodeExecutable(ctx, &run.Node)
+	} else if run.Python.Entry != "" || run.Python.Module != "" {
+		return e.runPythonExecutable(ctx, &run.Python)
+	} else if image := run.Docker.Image; image != "" && shellPath(run) == "" {
+		return e.runDockerImage(ctx, image)
+	} else {
+		return e.runShellExecutable(ctx, run)
+	}
+}
+
+func (e *Execer) runSourceExecutable(ctx context.Context, sources []*BinarySource) error {
 	binpath, err := e.downloadBinaryFromSources(sources)
 	if err != nil {
 		return err
@@ -84,19 +206,20 @@ func (e *Execer) runSourceExecutable(ctx context.Context, sources []string) erro
 		return nil
 	}
 
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
 	var cmds []*exec.Cmd
 	if runtime.GOOS != "windows" {
-		cmds = append(cmds, exec.Command("chmod", "+x", binpath))
+		cmds = append(cmds, process.Command(ctx, "chmod", "+x", binpath))
 	}
-	cmds = append(cmds, exec.Command(binpath))
-	return runCmds(ctx, cmds, e.Environ, e.Workdir, e.Stdout, e.Stderr)
+	cmds = append(cmds, process.Command(ctx, binpath))
+	return done(runCmds(ctx, cmds, e.Environ, e.Workdir, e.Stdout, e.Stderr))
 }
 
-func (e *Execer) downloadBinaryFromSources(sources []string) (string, error) {
+func (e *Execer) downloadBinaryFromSources(sources []*BinarySource) (string, error) {
 	var err error
 	var binpath string
 	for _, source := range sources {
-		if source != "" {
+		if source.Source != "" {
 			binpath, err = e.downloadBinary(source)
 			if err == nil {
 				return binpath, nil
@@ -108,19 +231,35 @@ func (e *Execer) downloadBinaryFromSources(sources []string) (string, error) {
 	return "", err
 }
 
-func (e *Execer) downloadBinary(source string) (string, error) {
-	parsedURL, err := NewMetadata(source, e.Ref).Generate()
+func (e *Execer) downloadBinary(source *BinarySource) (string, error) {
+	parsedURL, err := NewMetadata(source.Source, e.Ref, e.Sha, e.Environ).Generate()
 	if err != nil {
 		return "", err
 	}
+	checksum := source.Checksum
+	if e.Checksums != nil {
+		locked, ok := e.Checksums[parsedURL]
+		if !ok {
+			return "", fmt.Errorf("binary source not found in lockfile: %s", parsedURL)
+		}
+		checksum = locked
+	}
 	binpath, err := file.Download(parsedURL)
 	if err != nil {
 		return "", err
 	}
+	if checksum != "" {
+		if err := verifyChecksum(binpath, checksum); err != nil {
+			// remove the cached binary so that it is downloaded
+			// again on the next attempt.
+			os.RemoveAll(filepath.Dir(binpath))
+			return "", errors.Wrap(err, fmt.Sprintf("url: %s", parsedURL))
+		}
+	}
 	return binpath, nil
 }
 
-func (e *Execer) runGoExecutable(ctx context.Context, module string) error {
+func (e *Execer) runGoExecutable(ctx context.Context, module *Go) error {
 	// if the plugin is a Go module
 	binpath, err := e.buildGoExecutable(ctx, module)
 	if err != nil {
@@ -132,31 +271,53 @@ func (e *Execer) runGoExecutable(ctx context.Context, module string) error {
 		return nil
 	}
 
-	slog.Debug("go run", slog.String("module", module))
+	slog.Debug("go run", slog.String("module", module.Module))
 	// execute the binary
-	cmd := exec.Command(binpath)
-	return runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir, e.Stdout, e.Stderr)
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
+	cmd := process.Command(ctx, binpath)
+	return done(runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir, e.Stdout, e.Stderr))
 }
 
-func (e *Execer) runShellExecutable(ctx context.Context, out *spec) error {
+// helper function returns the path of the shell script that
+// is executed on the host operating system.
+func shellPath(run *Run) string {
+	if runtime.GOOS == "windows" {
+		return run.Pwsh.Path
+	}
+	return run.Bash.Path
+}
+
+func (e *Execer) runShellExecutable(ctx context.Context, run *Run) error {
 	if e.DownloadOnly {
 		slog.Info("Download only flag is set. Not executing the plugin")
 		return nil
 	}
 
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
 	switch runtime.GOOS {
 	case "windows":
 		// TODO we may want to disable profile and interactive mode
 		// when executing powershell scripts -noprofile -noninteractive
-		path := filepath.Join(e.Source, out.Run.Pwsh.Path)
+		path := filepath.Join(e.Source, run.Pwsh.Path)
+		args, err := expandArgs(run.Pwsh.Args, e.Environ)
+		if err != nil {
+			return err
+		}
+		for _, arg := range args {
+			path = path + " " + quotePwsh(arg)
+		}
 		slog.Debug("execute", slog.String("file", path))
 		script := fmt.Sprintf(
 			"$ErrorActionPreference = 'Stop'; $ProgressPreference = 'SilentlyContinue'; %s", path)
-		cmd := exec.Command("pwsh", "-Command", script)
+		cmd := process.Command(ctx, "pwsh", "-Command", script)
 		envWithClonePath := append(e.Environ, fmt.Sprintf("CLONE_CACHE_PATH=%s", e.Source))
-		return runCmds(ctx, []*exec.Cmd{cmd}, envWithClonePath, e.Workdir, e.Stdout, e.Stderr)
+		return done(runCmds(ctx, []*exec.Cmd{cmd}, envWithClonePath, e.Workdir, e.Stdout, e.Stderr))
 	case "linux", "darwin":
-		path := filepath.Join(e.Source, out.Run.Bash.Path)
+		path := filepath.Join(e.Source, run.Bash.Path)
+		args, err := expandArgs(run.Bash.Args, e.Environ)
+		if err != nil {
+			return err
+		}
 
 		// fallback to the posix shell if bash
 		// is not available on the host.
@@ -166,108 +327,112 @@ func (e *Execer) runShellExecutable(ctx context.Context, out *spec) error {
 		}
 		slog.Debug("execute", slog.String("file", path))
 
-		cmd := exec.Command(shell, path)
+		cmd := process.Command(ctx, shell, append([]string{path}, args...)...)
 		envWithClonePath := append(e.Environ, fmt.Sprintf("CLONE_CACHE_PATH=%s", e.Source))
-		return runCmds(ctx, []*exec.Cmd{cmd}, envWithClonePath, e.Workdir, e.Stdout, e.Stderr)
+		return done(runCmds(ctx, []*exec.Cmd{cmd}, envWithClonePath, e.Workdir, e.Stdout, e.Stderr))
 	default:
-		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		return done(fmt.Errorf("unsupported operating system: %s", runtime.GOOS))
 	}
 }
 
-func (e *Execer) buildGoExecutable(ctx context.Context, module string) (
-	string, error) {
-	defer timer("buildGoExecutable")()
-	key := e.Source
-	binpath := filepath.Join(e.Source, "step.exe")
-
-	buildFn := func() error {
-		slog.Debug("go build", slog.String("module", module))
+func (e *Execer) runDockerImage(ctx context.Context, image string) error {
+	if e.DownloadOnly {
+		slog.Info("Download only flag is set. Not executing the plugin")
+		return nil
+	}
 
-		// compile the code
-		cmd := exec.Command("go", "build", "-o", binpath, module)
-		return runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Source, e.Stdout, e.Stderr)
+	client, err := docker.NewClient(e.DockerHost)
+	if err != nil {
+		return err
 	}
 
-	if err := cache.Add(key, buildFn); err != nil {
-		return "", err
+	binds := []string{e.Workdir + ":" + e.Workdir}
+	if e.outputPath != "" {
+		dir := filepath.Dir(e.outputPath)
+		binds = append(binds, dir+":"+dir)
 	}
-	return binpath, nil
+
+	slog.Debug("docker run", slog.String("image", image))
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
+	return done(client.Run(ctx, &docker.RunConfig{
+		Image:   image,
+		Env:     dockerEnviron(e.Environ, e.pluginEnv),
+		Workdir: e.Workdir,
+		Binds:   binds,
+		Stdout:  e.Stdout,
+		Stderr:  e.Stderr,
+
+		StopTimeout: process.GracePeriod(ctx),
+	}))
 }
 
-func (e *Execer) installAptDeps(ctx context.Context, deps []string, sources []*AptSource) {
-	for _, source := range sources {
-		if source == nil || source.Key == "" || source.Data == "" {
-			slog.Info("Either key or data is not set", slog.String("source", source.Data), slog.String("key", source.Key))
-			continue
-		}
+func (e *Execer) buildGoExecutable(ctx context.Context, module *Go) (
+	string, error) {
+	defer timer("buildGoExecutable")()
 
-		cmdStr := fmt.Sprintf("wget -qO - %s | sudo apt-key add -\n echo \"%s\" | sudo tee -a /etc/apt/sources.list", source.Key, source.Data)
-		slog.Info("add-apt-repository", slog.String("cmd", cmdStr))
-		cmd := exec.Command("bash", "-c", cmdStr)
-		if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir,
-			e.Stdout, e.Stderr); err != nil {
-			slog.Error("add-apt-repository failed", "error", err)
-		}
+	// the go version is included in the cache key so that
+	// the plugin is rebuilt when the toolchain changes.
+	version, err := e.output(ctx, e.Environ, "go", "env", "GOVERSION")
+	if err != nil {
+		return "", errors.Wrap(err, "cannot determine go version")
 	}
+	env := goBuildEnviron(module, cache.GoEnviron(e.Environ))
+	flags := goBuildFlags(module)
 
-	if len(deps) > 0 {
-		slog.Debug("apt-get update")
-
-		cmd := exec.Command("sudo", "apt-get", "update")
-		if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir,
-			e.Stdout, e.Stderr); err != nil {
-			slog.Error("apt-get update failed", "error", err)
-		}
-
+	if isRemoteModule(module.Module) {
+		key := cache.GetKeyName(goBuildKey("", module, strings.TrimSpace(version)))
+		return e.installGoModule(ctx, key, module.Module, flags, env)
 	}
 
-	for _, item := range deps {
-		slog.Debug("apt-get install", slog.String("package", item))
+	key := cache.GetKeyName(goBuildKey(e.Source, module, strings.TrimSpace(version)))
+	binpath := filepath.Join(key, "step.exe")
 
-		cmd := exec.Command("sudo", "apt-get", "install", item)
-		if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir,
-			e.Stdout, e.Stderr); err != nil {
-			slog.Error("apt-get install failed", slog.String("package", item), "error", err)
-		}
-	}
-}
+	buildFn := func() error {
+		slog.Debug("go build", slog.String("module", module.Module))
 
-func (e *Execer) installBrewDeps(ctx context.Context, deps []string) {
-	for _, item := range deps {
-		slog.Debug("brew install", slog.String("package", item))
+		// compile the code
+		ctx, done := process.WithTimeout(ctx, "build", e.BuildTimeout)
+		args := append(append([]string{"build", "-o", binpath}, flags...), module.Module)
+		cmd := process.Command(ctx, "go", args...)
+		return done(runCmds(ctx, []*exec.Cmd{cmd}, env, e.Source, e.Stdout, e.Stderr))
+	}
 
-		cmd := exec.Command("brew", "install", item)
-		if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir,
-			e.Stdout, e.Stderr); err != nil {
-			slog.Error("brew install failed", slog.String("package", item), "error", err)
-		}
+	if err := cache.Add(key, buildFn); err != nil {
+		return "", err
 	}
+	return binpath, nil
 }
 
-func (e *Execer) installChocoDeps(ctx context.Context, deps []string) {
-	for _, item := range deps {
-		slog.Debug("choco install", slog.String("package", item))
-
-		cmd := exec.Command("choco", "install", item)
-		if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir,
-			e.Stdout, e.Stderr); err != nil {
-			slog.Error("choco install failed", slog.String("package", item), "error", err)
-		}
+// installGoModule installs the versioned go module using go
+// install, without cloning the plugin source code.
+func (e *Execer) installGoModule(ctx context.Context, key, module string, flags, env []string) (
+	string, error) {
+	bindir := filepath.Join(key, "bin")
+
+	installFn := func() error {
+		slog.Debug("go install", slog.String("module", module))
+
+		// the module is installed from outside of any
+		// module directory, to prevent the current module
+		// from affecting the build.
+		ctx, done := process.WithTimeout(ctx, "build", e.BuildTimeout)
+		args := append(append([]string{"install"}, flags...), module)
+		cmd := process.Command(ctx, "go", args...)
+		env := append(env[:len(env):len(env)], "GOBIN="+bindir)
+		return done(runCmds(ctx, []*exec.Cmd{cmd}, env, key, e.Stdout, e.Stderr))
 	}
-}
 
-func (e *Execer) installRunScripts(ctx context.Context, cmds []string) {
-	shell := "bash"
-	if _, err := exec.LookPath("bash"); err != nil {
-		shell = "sh"
+	if err := cache.Add(key, installFn); err != nil {
+		return "", err
 	}
-	for _, item := range cmds {
-		cmd := exec.Command(shell, "-c", item)
-		if err := runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir,
-			e.Stdout, e.Stderr); err != nil {
-			slog.Error("run command failed", slog.String("command", item), "error", err)
-		}
+
+	// go install names the binary after the module path
+	// so the binary name is looked up in the bin directory.
+	entries, err := os.ReadDir(bindir)
+	if err != nil || len(entries) != 1 {
+		return "", fmt.Errorf("cannot find go binary for module: %s", module)
 	}
+	return filepath.Join(bindir, entries[0].Name()), nil
 }
 
 func runCmds(ctx context.Context, cmds []*exec.Cmd, env []string, workdir string,
@@ -286,18 +451,51 @@ func runCmds(ctx context.Context, cmds []*exec.Cmd, env []string, workdir string
 	return nil
 }
 
-func (e *Execer) getBinarySources(source string, fallback string) []string {
-	sources := []string{}
-	if len(e.BinarySources.GetValue()) > 0 {
-		sources = append(sources, e.BinarySources.GetValue()...)
+// helper function returns the plugin and drone environment
+// variables, and the environment variables added by the
+// plugin, that are passed through to the docker container.
+func dockerEnviron(env []string, added []string) []string {
+	keys := map[string]bool{}
+	for _, key := range added {
+		keys[key] = true
+	}
+	var out []string
+	for _, v := range env {
+		key, _, _ := strings.Cut(v, "=")
+		if strings.HasPrefix(key, "PLUGIN_") || strings.HasPrefix(key, "DRONE_") || keys[key] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getBinarySources returns the binary sources in order of
+// precedence: the sources provided by flag, the source that
+// matches the platform, the source and the fallback source.
+// An error is returned if the plugin only provides platform
+// sources, and none matches the platform.
+func getBinarySources(flags []string, binary Binary, goos, goarch string) ([]*BinarySource, error) {
+	sources := []*BinarySource{}
+	for _, source := range flags {
+		sources = append(sources, &BinarySource{Source: source})
+	}
+	if len(binary.Platforms) > 0 {
+		source, err := matchPlatform(binary.Platforms, goos, goarch)
+		if err == nil {
+			sources = append(sources, source)
+		} else if len(sources) == 0 && binary.Source == "" && binary.FallbackSource == "" {
+			return nil, err
+		} else {
+			slog.Debug("no platform binary source", "error", err)
+		}
 	}
-	if source != "" {
-		sources = append(sources, source)
+	if binary.Source != "" {
+		sources = append(sources, &BinarySource{Source: binary.Source})
 	}
-	if fallback != "" {
-		sources = append(sources, fallback)
+	if binary.FallbackSource != "" {
+		sources = append(sources, &BinarySource{Source: binary.FallbackSource})
 	}
-	return sources
+	return sources, nil
 }
 
 // trace writes each command to stdout with the command wrapped in an xml
@@ -1,9 +1,20 @@
 package harness
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/drone/plugin/utils"
+	"github.com/drone/plugin/plugin/internal/process"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,11 +58,176 @@ func TestGetBinarySources(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			execer := &Execer{
-				BinarySources: utils.CustomStringSliceFlag{Value: tt.binarySources},
-			}
-			result := execer.getBinarySources(tt.source, tt.fallback)
-			assert.Equal(t, tt.expected, result)
+			result, err := getBinarySources(tt.binarySources, Binary{Source: tt.source, FallbackSource: tt.fallback},
+				runtime.GOOS, runtime.GOARCH)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sourceURLs(result))
 		})
 	}
 }
+
+func TestGetBinarySources_Platforms(t *testing.T) {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	platforms := map[string]*BinarySource{
+		"*/*":               {Source: "any"},
+		runtime.GOOS + "/*": {Source: "os"},
+		platform:            {Source: "exact", Checksum: "sha256:abc"},
+	}
+
+	result, err := getBinarySources([]string{"binary1"}, Binary{Source: "main-source", Platforms: platforms},
+		runtime.GOOS, runtime.GOARCH)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"binary1", "exact", "main-source"}, sourceURLs(result))
+	assert.Equal(t, "sha256:abc", result[1].Checksum)
+
+	// if no platform matches the host, the error lists
+	// the supported platforms.
+	_, err = getBinarySources(nil, Binary{Platforms: map[string]*BinarySource{
+		"plan9/mips": {Source: "plan9"},
+		"aix/ppc64":  {Source: "aix"},
+	}}, runtime.GOOS, runtime.GOARCH)
+	platformErr := new(PlatformError)
+	if assert.True(t, errors.As(err, &platformErr)) {
+		assert.Equal(t, platform, platformErr.Platform)
+		assert.Equal(t, []string{"aix/ppc64", "plan9/mips"}, platformErr.Supported)
+	}
+
+	// if no platform matches the host, the source
+	// template is used.
+	result, err = getBinarySources(nil, Binary{Source: "main-source", Platforms: map[string]*BinarySource{
+		"plan9/mips": {Source: "plan9"},
+	}}, runtime.GOOS, runtime.GOARCH)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main-source"}, sourceURLs(result))
+
+	// the sources are resolved for the provided platform.
+	result, err = getBinarySources(nil, Binary{Platforms: map[string]*BinarySource{
+		"plan9/mips": {Source: "plan9"},
+	}}, "plan9", "mips")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"plan9"}, sourceURLs(result))
+}
+
+// helper function returns the binary source urls.
+func sourceURLs(sources []*BinarySource) []string {
+	urls := []string{}
+	for _, source := range sources {
+		urls = append(urls, source.Source)
+	}
+	return urls
+}
+
+func TestDockerEnviron(t *testing.T) {
+	env := []string{
+		"HOME=/root",
+		"PATH=/usr/bin",
+		"PLUGIN_URLS=http://example.com",
+		"DRONE_COMMIT_SHA=7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+		"GITHUB_TOKEN=secret",
+	}
+	expected := []string{
+		"PLUGIN_URLS=http://example.com",
+		"DRONE_COMMIT_SHA=7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+	}
+	assert.Equal(t, expected, dockerEnviron(env, nil))
+
+	// environment variables added by the plugin are passed
+	// through to the container.
+	expected = append(expected, "GITHUB_TOKEN=secret")
+	assert.Equal(t, expected, dockerEnviron(env, []string{"GITHUB_TOKEN"}))
+}
+
+func TestExecDockerEnviron(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the pwsh script is executed on windows")
+	}
+
+	var created struct {
+		Env []string
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(201)
+			w.Write([]byte(`{"Id":"abc123"}`))
+		case strings.HasSuffix(r.URL.Path, "/wait"):
+			w.Write([]byte(`{"StatusCode":0}`))
+		}
+	})
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("unix sockets not supported")
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	source := t.TempDir()
+	// the pwsh script is only executed on windows, so the
+	// docker image is executed on this host.
+	yaml := `
+inputs:
+  - name: token
+    env: GITHUB_TOKEN
+env:
+  GH_HOST: github.example.com
+run:
+  docker:
+    image: plugins/gh
+  pwsh:
+    path: run.ps1
+`
+	if err := os.WriteFile(filepath.Join(source, "plugin.yml"), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Execer{
+		Source:     source,
+		Workdir:    t.TempDir(),
+		DockerHost: "unix://" + sock,
+		Environ:    []string{"HOME=/root", "PLUGIN_TOKEN=secret"},
+		Stdout:     io.Discard,
+		Stderr:     io.Discard,
+	}
+	if err := e.Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []string{
+		"GH_HOST=github.example.com",
+		"GITHUB_TOKEN=secret",
+		"PLUGIN_TOKEN=secret",
+	}, created.Env)
+}
+
+func TestExecRunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell required")
+	}
+
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "plugin.yml"), []byte("run:\n  bash:\n    path: run.sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "run.sh"), []byte("sleep 30\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Execer{
+		Source:     source,
+		Workdir:    t.TempDir(),
+		Stdout:     io.Discard,
+		Stderr:     io.Discard,
+		RunTimeout: 100 * time.Millisecond,
+	}
+	err := e.Exec(context.Background())
+
+	timeout := new(process.TimeoutError)
+	if !errors.As(err, &timeout) {
+		t.Fatalf("Want timeout error, got %v", err)
+	}
+	assert.Equal(t, "run", timeout.Phase)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"strconv"
+	"strings"
+)
+
+// helper function returns true if the module is a fully
+// qualified module path with version (module@version).
+func isRemoteModule(module string) bool {
+	return strings.Contains(module, "@")
+}
+
+// helper function returns the go build flags.
+func goBuildFlags(module *Go) []string {
+	var flags []string
+	if module.Trimpath {
+		flags = append(flags, "-trimpath")
+	}
+	if module.Ldflags != "" {
+		flags = append(flags, "-ldflags="+module.Ldflags)
+	}
+	if len(module.Tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(module.Tags, ","))
+	}
+	return flags
+}
+
+// helper function returns the go build environment. The
+// CGO_ENABLED variable is set if cgo is configured in the
+// plugin yaml, otherwise it is inherited.
+func goBuildEnviron(module *Go, env []string) []string {
+	if module.Cgo == nil {
+		return env
+	}
+	out := make([]string, 0, len(env)+1)
+	for _, v := range env {
+		if !strings.HasPrefix(v, "CGO_ENABLED=") {
+			out = append(out, v)
+		}
+	}
+	if *module.Cgo {
+		return append(out, "CGO_ENABLED=1")
+	}
+	return append(out, "CGO_ENABLED=0")
+}
+
+// helper function returns the go build cache key, which
+// includes the go toolchain version and build flags so
+// that the plugin is rebuilt when either changes.
+func goBuildKey(source string, module *Go, version string) string {
+	parts := []string{source, module.Module, version}
+	if module.Cgo != nil {
+		parts = append(parts, "cgo="+strconv.FormatBool(*module.Cgo))
+	}
+	parts = append(parts, goBuildFlags(module)...)
+	return strings.Join(parts, "\n")
+}
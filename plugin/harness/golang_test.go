@@ -0,0 +1,54 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRemoteModule(t *testing.T) {
+	assert.True(t, isRemoteModule("github.com/org/tool/cmd/x@v1.2.3"))
+	assert.True(t, isRemoteModule("github.com/org/tool@latest"))
+	assert.False(t, isRemoteModule("./cmd/x"))
+	assert.False(t, isRemoteModule("main.go"))
+}
+
+func TestGoBuildFlags(t *testing.T) {
+	module := &Go{
+		Trimpath: true,
+		Ldflags:  "-s -w -X main.version=1.0.0",
+		Tags:     []string{"netgo", "osusergo"},
+	}
+	expected := []string{
+		"-trimpath",
+		"-ldflags=-s -w -X main.version=1.0.0",
+		"-tags=netgo,osusergo",
+	}
+	assert.Equal(t, expected, goBuildFlags(module))
+	assert.Empty(t, goBuildFlags(&Go{}))
+}
+
+func TestGoBuildEnviron(t *testing.T) {
+	env := []string{"HOME=/root", "CGO_ENABLED=1"}
+	disabled := false
+
+	assert.Equal(t, env, goBuildEnviron(&Go{}, env))
+	assert.Equal(t, []string{"HOME=/root", "CGO_ENABLED=0"}, goBuildEnviron(&Go{Cgo: &disabled}, env))
+	assert.Equal(t, []string{"HOME=/root", "CGO_ENABLED=1"}, env)
+}
+
+func TestGoBuildKey(t *testing.T) {
+	module := &Go{Module: "github.com/org/tool/cmd/x@v1.2.3"}
+	key := goBuildKey("", module, "go1.21.0")
+
+	assert.NotEqual(t, key, goBuildKey("", module, "go1.22.0"), "Want key to change with toolchain")
+	assert.NotEqual(t, key, goBuildKey("", &Go{Module: module.Module, Trimpath: true}, "go1.21.0"), "Want key to change with flags")
+
+	disabled := false
+	assert.NotEqual(t, key, goBuildKey("", &Go{Module: module.Module, Cgo: &disabled}, "go1.21.0"), "Want key to change with cgo")
+	assert.Equal(t, key, goBuildKey("", &Go{Module: module.Module}, "go1.21.0"))
+}
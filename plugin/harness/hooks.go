@@ -0,0 +1,163 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/drone/plugin/plugin/docker"
+	"github.com/drone/plugin/plugin/internal/process"
+	"golang.org/x/exp/slog"
+)
+
+// hook conditions.
+const (
+	hookAlways    = "always"
+	hookOnSuccess = "on-success"
+	hookOnFailure = "on-failure"
+)
+
+// environment variables that expose the step status to the
+// post hooks.
+const (
+	statusEnv       = "DRONE_PLUGIN_STATUS"
+	exitCodeEnv     = "DRONE_PLUGIN_EXIT_CODE"
+	outputPrefixEnv = "DRONE_PLUGIN_OUTPUT_"
+)
+
+// runPreHooks executes the pre hooks. The returned error is
+// the first hook error.
+func (e *Execer) runPreHooks(ctx context.Context, hooks HookList) error {
+	return e.runHooks(ctx, "pre", hooks, hookOnSuccess, nil)
+}
+
+// runPostHooks executes the post hooks with the step status
+// and outputs exposed in the environment. The post hooks are
+// executed even if the step is terminated, in which case the
+// hooks are given the grace period to complete. The returned
+// error is the step error, or the first hook error if the
+// step succeeded.
+func (e *Execer) runPostHooks(ctx context.Context, hooks HookList, err error) error {
+	if len(hooks) == 0 {
+		return err
+	}
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), process.GracePeriod(ctx))
+		defer cancel()
+	}
+	e.Environ = append(e.Environ, e.statusEnviron(err)...)
+	return e.runHooks(ctx, "post", hooks, hookAlways, err)
+}
+
+// runHooks executes the hooks with conditions that match the
+// step status and the host platform.
+func (e *Execer) runHooks(ctx context.Context, phase string, hooks HookList, fallback string, err error) error {
+	if e.DownloadOnly {
+		return err
+	}
+	host := e.hostPlatform()
+	for i, hook := range hooks {
+		if hook == nil {
+			continue
+		}
+		on := hook.On
+		if on == "" {
+			on = fallback
+		}
+		switch on {
+		case hookAlways:
+		case hookOnSuccess, "success":
+			if err != nil {
+				slog.Debug(phase+" hook skipped", slog.Int("index", i), slog.String("reason", "step failed"))
+				continue
+			}
+		case hookOnFailure, "failure":
+			if err == nil {
+				slog.Debug(phase+" hook skipped", slog.Int("index", i), slog.String("reason", "step succeeded"))
+				continue
+			}
+		default:
+			slog.Warn("unknown hook condition", slog.String("phase", phase), slog.String("on", hook.On))
+			continue
+		}
+		if ok, reason := hook.When.match(host); !ok {
+			slog.Debug(phase+" hook skipped", slog.Int("index", i), slog.String("reason", reason))
+			continue
+		}
+
+		slog.Debug(phase+" hook", slog.Int("index", i), slog.String("on", on))
+		if herr := e.runHook(ctx, &hook.Run); herr != nil {
+			slog.Error(phase+" hook failed", slog.Int("index", i), "error", herr)
+			if err == nil {
+				err = fmt.Errorf("%s hook failed: %w", phase, herr)
+			}
+		}
+	}
+	return err
+}
+
+// runHook executes the hook run block. Unlike the main run
+// block, binary sources provided by flag are ignored.
+func (e *Execer) runHook(ctx context.Context, run *Run) error {
+	sources, err := getBinarySources(nil, run.Binary, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	return e.runBlock(ctx, run, sources)
+}
+
+// statusEnviron returns the environment variables that expose
+// the step status, exit code and outputs to the post hooks.
+func (e *Execer) statusEnviron(err error) []string {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	env := []string{
+		statusEnv + "=" + status,
+		exitCodeEnv + "=" + strconv.Itoa(exitCode(err)),
+	}
+	if e.outputPath == "" {
+		return env
+	}
+	outputs, rerr := readOutputs(e.outputPath)
+	if rerr != nil {
+		slog.Warn("cannot read outputs", "error", rerr)
+		return env
+	}
+	var names []string
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, outputPrefixEnv+strings.ToUpper(name)+"="+outputs[name])
+	}
+	return env
+}
+
+// helper function returns the exit code of the error.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	var dockerErr *docker.ExitError
+	if errors.As(err, &dockerErr) {
+		return dockerErr.Code
+	}
+	return 1
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/drone/plugin/plugin/docker"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell required")
+	}
+
+	tests := []struct {
+		name     string
+		exit     string
+		expected string
+		outputs  map[string]string
+		err      bool
+	}{
+		{
+			name:     "success",
+			exit:     "exit 0",
+			expected: "pre\nrun\npost-always success 0 1.0.0\npost-success\n",
+			outputs:  map[string]string{"version": "1.0.0"},
+		},
+		{
+			name:     "failure",
+			exit:     "exit 3",
+			expected: "pre\nrun\npost-always failure 3 1.0.0\npost-failure\n",
+			err:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := t.TempDir()
+			log := filepath.Join(t.TempDir(), "log")
+			if err := os.WriteFile(filepath.Join(source, "plugin.yml"), []byte(`
+outputs:
+  - name: version
+pre:
+  bash:
+    path: pre.sh
+run:
+  bash:
+    path: run.sh
+post:
+  - bash:
+      path: post.sh
+      args: [ always ]
+  - on: on-success
+    bash:
+      path: post.sh
+      args: [ success ]
+  - on: on-failure
+    bash:
+      path: post.sh
+      args: [ failure ]
+`), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(source, "pre.sh"), []byte("echo pre >> "+log+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(source, "run.sh"), []byte("echo run >> "+log+"\necho VERSION=1.0.0 >> $DRONE_PLUGIN_OUTPUT\n"+tt.exit+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(source, "post.sh"), []byte(`if [ "$1" = always ]; then
+  echo "post-$1 $DRONE_PLUGIN_STATUS $DRONE_PLUGIN_EXIT_CODE $DRONE_PLUGIN_OUTPUT_VERSION" >> `+log+`
+else
+  echo "post-$1" >> `+log+`
+fi
+`), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			e := &Execer{
+				Source:     source,
+				Workdir:    t.TempDir(),
+				Environ:    []string{"PATH=" + os.Getenv("PATH")},
+				Stdout:     io.Discard,
+				Stderr:     io.Discard,
+				OutputFile: filepath.Join(t.TempDir(), "output.env"),
+			}
+			err := e.Exec(context.Background())
+			assert.Equal(t, tt.err, err != nil, "Want error %v, got %v", tt.err, err)
+
+			got, _ := os.ReadFile(log)
+			assert.Equal(t, tt.expected, string(got))
+
+			// the outputs are matched case insensitively, and
+			// are only saved if the step succeeds.
+			outputs, err := godotenv.Read(e.OutputFile)
+			if tt.outputs == nil {
+				assert.True(t, os.IsNotExist(err), "Want no output file, got %v", err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.outputs, outputs)
+			}
+		})
+	}
+}
+
+func TestExecHooks_PreFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell required")
+	}
+
+	source := t.TempDir()
+	log := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(filepath.Join(source, "plugin.yml"), []byte(`
+pre:
+  bash:
+    path: pre.sh
+run:
+  bash:
+    path: run.sh
+post:
+  on: on-failure
+  bash:
+    path: post.sh
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "pre.sh"), []byte("exit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "run.sh"), []byte("echo run >> "+log+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "post.sh"), []byte("echo post $DRONE_PLUGIN_STATUS >> "+log+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &Execer{
+		Source:  source,
+		Workdir: t.TempDir(),
+		Environ: []string{"PATH=" + os.Getenv("PATH")},
+		Stdout:  io.Discard,
+		Stderr:  io.Discard,
+	}
+	err := e.Exec(context.Background())
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "pre hook failed"), "Want pre hook error, got %v", err)
+	}
+
+	got, _ := os.ReadFile(log)
+	assert.Equal(t, "post failure\n", string(got))
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, exitCode(nil))
+	assert.Equal(t, 1, exitCode(errors.New("failed")))
+	assert.Equal(t, 2, exitCode(&docker.ExitError{Image: "alpine", Code: 2}))
+
+	if runtime.GOOS != "windows" {
+		err := exec.Command("sh", "-c", "exit 4").Run()
+		assert.Equal(t, 4, exitCode(err))
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/drone/plugin/plugin/internal/encoder"
+	"github.com/drone/plugin/plugin/internal/environ"
+	"golang.org/x/exp/slog"
+)
+
+// input types supported by the plugin.yml inputs section.
+const (
+	inputString  = "string"
+	inputBoolean = "boolean"
+	inputNumber  = "number"
+	inputInteger = "integer"
+	inputList    = "list"
+	inputJSON    = "json"
+)
+
+// validateInputs validates the plugin environment against
+// the declared inputs. Default values are applied, values
+// are coerced to the declared type, and an error is returned
+// if a required input is missing or a value is invalid. A
+// warning is logged for PLUGIN_ prefixed environment
+// variables that do not match a declared input.
+func validateInputs(inputs []*Input, env []string) ([]string, error) {
+	if len(inputs) == 0 {
+		return env, nil
+	}
+
+	envs := environ.Map(env)
+	known := map[string]bool{}
+
+	var missing []string
+	for _, in := range inputs {
+		if in == nil || in.Name == "" {
+			continue
+		}
+		key := inputKey(in.Name)
+		known[key] = true
+
+		val, ok := envs[key]
+		if !ok || val == "" {
+			if in.Default != nil {
+				val = encoder.Encode(in.Default)
+			} else if in.Required {
+				missing = append(missing, in.Name)
+				continue
+			} else {
+				continue
+			}
+		}
+
+		val, err := coerceInput(in, val)
+		if err != nil {
+			return nil, err
+		}
+		envs[key] = val
+
+		if in.Secret {
+			slog.Debug("input", slog.String("name", in.Name), slog.String("value", "******"))
+		} else {
+			slog.Debug("input", slog.String("name", in.Name), slog.String("value", val))
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required inputs: %s", strings.Join(missing, ", "))
+	}
+
+	// warn about unknown inputs, which are often the
+	// result of a typo in the pipeline yaml.
+	var unknown []string
+	for key := range envs {
+		if strings.HasPrefix(key, "PLUGIN_") && !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		name := strings.ToLower(strings.TrimPrefix(key, "PLUGIN_"))
+		if match := suggestInput(name, inputs); match != "" {
+			slog.Warn("unknown input", slog.String("name", name), slog.String("did_you_mean", match))
+		} else {
+			slog.Warn("unknown input", slog.String("name", name))
+		}
+	}
+
+	return environ.Slice(envs), nil
+}
+
+// coerceInput validates and normalizes the input value
+// based on the declared input type and enum.
+func coerceInput(in *Input, val string) (string, error) {
+	switch strings.ToLower(in.Type) {
+	case "", inputString:
+	case inputBoolean, "bool":
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "true", "yes", "on", "1", "y":
+			val = "true"
+		case "false", "no", "off", "0", "n":
+			val = "false"
+		default:
+			return "", fmt.Errorf("input %s: invalid boolean value %q", in.Name, val)
+		}
+	case inputNumber:
+		val = strings.TrimSpace(val)
+		if _, err := strconv.ParseFloat(val, 64); err != nil {
+			return "", fmt.Errorf("input %s: invalid number value %q", in.Name, val)
+		}
+	case inputInteger, "int":
+		val = strings.TrimSpace(val)
+		if _, err := strconv.ParseInt(val, 10, 64); err != nil {
+			return "", fmt.Errorf("input %s: invalid integer value %q", in.Name, val)
+		}
+	case inputList, "array":
+		// lists may be provided as a json array, which
+		// are converted to comma separated values for
+		// consistency with drone plugin settings.
+		var items []interface{}
+		if err := json.Unmarshal([]byte(val), &items); err == nil {
+			val = encoder.Encode(items)
+		}
+	case inputJSON, "object":
+		if !json.Valid([]byte(val)) {
+			return "", fmt.Errorf("input %s: invalid json value", in.Name)
+		}
+	default:
+		return "", fmt.Errorf("input %s: unsupported type %s", in.Name, in.Type)
+	}
+
+	if len(in.Enum) > 0 {
+		for _, item := range in.Enum {
+			if item == val {
+				return val, nil
+			}
+		}
+		return "", fmt.Errorf("input %s: value %q must be one of [%s]",
+			in.Name, val, strings.Join(in.Enum, ", "))
+	}
+	return val, nil
+}
+
+// inputKey returns the environment variable name for the
+// named input.
+func inputKey(name string) string {
+	name = strings.ReplaceAll(name, "-", "_")
+	name = strings.ReplaceAll(name, ".", "_")
+	return "PLUGIN_" + strings.ToUpper(name)
+}
+
+// suggestInput returns the declared input name closest to
+// the unknown name, or an empty string if no input is a
+// reasonable match.
+func suggestInput(name string, inputs []*Input) string {
+	best, bestDist := "", -1
+	for _, in := range inputs {
+		if in == nil || in.Name == "" {
+			continue
+		}
+		candidate := strings.ToLower(strings.TrimPrefix(inputKey(in.Name), "PLUGIN_"))
+		dist := levenshtein(name, candidate)
+		if bestDist == -1 || dist < bestDist {
+			best, bestDist = in.Name, dist
+		}
+	}
+	// limit suggestions to names that differ by a small
+	// number of edits relative to the name length.
+	if bestDist == -1 || bestDist > 2 || bestDist > len(name)/2 {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"testing"
+
+	"github.com/drone/plugin/plugin/internal/environ"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInputs(t *testing.T) {
+	inputs := []*Input{
+		{Name: "url", Required: true},
+		{Name: "method", Default: "POST", Enum: []string{"GET", "POST"}},
+		{Name: "debug", Type: "boolean", Default: false},
+		{Name: "timeout", Type: "integer", Default: 30},
+		{Name: "headers", Type: "list"},
+		{Name: "token", Secret: true},
+	}
+
+	tests := []struct {
+		name     string
+		env      []string
+		expected map[string]string
+		wantErr  string
+	}{
+		{
+			name: "defaults applied",
+			env:  []string{"PLUGIN_URL=http://example.com"},
+			expected: map[string]string{
+				"PLUGIN_URL":     "http://example.com",
+				"PLUGIN_METHOD":  "POST",
+				"PLUGIN_DEBUG":   "false",
+				"PLUGIN_TIMEOUT": "30",
+			},
+		},
+		{
+			name: "values coerced",
+			env: []string{
+				"PLUGIN_URL=http://example.com",
+				"PLUGIN_DEBUG=yes",
+				"PLUGIN_HEADERS=[\"a\",\"b\"]",
+				"PLUGIN_TOKEN=secret",
+			},
+			expected: map[string]string{
+				"PLUGIN_URL":     "http://example.com",
+				"PLUGIN_METHOD":  "POST",
+				"PLUGIN_DEBUG":   "true",
+				"PLUGIN_TIMEOUT": "30",
+				"PLUGIN_HEADERS": "a,b",
+				"PLUGIN_TOKEN":   "secret",
+			},
+		},
+		{
+			name: "unknown inputs passed through",
+			env:  []string{"PLUGIN_URL=http://example.com", "PLUGIN_URLS=http://example.com"},
+			expected: map[string]string{
+				"PLUGIN_URL":     "http://example.com",
+				"PLUGIN_URLS":    "http://example.com",
+				"PLUGIN_METHOD":  "POST",
+				"PLUGIN_DEBUG":   "false",
+				"PLUGIN_TIMEOUT": "30",
+			},
+		},
+		{
+			name:    "missing required",
+			env:     []string{},
+			wantErr: "missing required inputs: url",
+		},
+		{
+			name:    "invalid enum",
+			env:     []string{"PLUGIN_URL=http://example.com", "PLUGIN_METHOD=PUT"},
+			wantErr: `input method: value "PUT" must be one of [GET, POST]`,
+		},
+		{
+			name:    "invalid boolean",
+			env:     []string{"PLUGIN_URL=http://example.com", "PLUGIN_DEBUG=maybe"},
+			wantErr: `input debug: invalid boolean value "maybe"`,
+		},
+		{
+			name:    "invalid integer",
+			env:     []string{"PLUGIN_URL=http://example.com", "PLUGIN_TIMEOUT=1.5"},
+			wantErr: `input timeout: invalid integer value "1.5"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateInputs(inputs, tt.env)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, environ.Map(got))
+		})
+	}
+}
+
+func TestValidateInputs_Undeclared(t *testing.T) {
+	env := []string{"PLUGIN_ANYTHING=1"}
+	got, err := validateInputs(nil, env)
+	assert.NoError(t, err)
+	assert.Equal(t, env, got)
+}
+
+func TestSuggestInput(t *testing.T) {
+	inputs := []*Input{
+		{Name: "url"},
+		{Name: "log-level"},
+		{Name: "webhook_token"},
+	}
+	tests := map[string]string{
+		"urls":          "url",
+		"log_levle":     "log-level",
+		"webhook_tokn":  "webhook_token",
+		"something":     "",
+		"x":             "",
+		"webhook_token": "webhook_token",
+	}
+	for name, want := range tests {
+		if got := suggestInput(name, inputs); got != want {
+			t.Errorf("Want suggestion %q for %q, got %q", want, name, got)
+		}
+	}
+}
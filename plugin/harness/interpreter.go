@@ -0,0 +1,81 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// interpreterVersion returns the version of the interpreter
+// installed on the host, and returns an error if the version
+// does not match the required version.
+func (e *Execer) interpreterVersion(ctx context.Context, name, want string, args ...string) (string, error) {
+	out, err := e.output(ctx, e.Environ, args...)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("%s is not installed", name))
+	}
+	// trim the version prefix (e.g. v18.0.0 or Python 3.11.0)
+	version := strings.TrimSpace(out)
+	version = strings.TrimPrefix(version, "Python ")
+	version = strings.TrimPrefix(version, "v")
+
+	if want != "" && !matchInterpreterVersion(version, want) {
+		return "", fmt.Errorf("%s version %s does not match required version %s", name, version, want)
+	}
+	return version, nil
+}
+
+// helper function returns true if the version matches the
+// required version. The required version matches by version
+// component (e.g. 18 matches 18.1.0, but not 180.0.0).
+func matchInterpreterVersion(version, want string) bool {
+	want = strings.TrimPrefix(strings.TrimSpace(want), "v")
+	if strings.HasSuffix(want, "*") {
+		return matchVersion(version, want)
+	}
+	return version == want || strings.HasPrefix(version, want+".")
+}
+
+// helper function returns a hash of the file contents.
+func hashFiles(paths ...string) (string, error) {
+	h := sha256.New()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, filepath.Base(path)+"\n")
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// helper function copies the file to the directory.
+func copyFile(path, dir string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, filepath.Base(path)), data, 0644)
+}
+
+// helper function returns true if the file exists.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
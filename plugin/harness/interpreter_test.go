@@ -0,0 +1,61 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchInterpreterVersion(t *testing.T) {
+	tests := []struct {
+		version, want string
+		match         bool
+	}{
+		{"18.17.1", "18", true},
+		{"18.17.1", "v18", true},
+		{"18.17.1", "18.17", true},
+		{"18.17.1", "18.17.1", true},
+		{"18.17.1", "18.*", true},
+		{"180.0.0", "18", false},
+		{"20.19.5", "18", false},
+		{"3.11.7", "3.1", false},
+		{"3.11.7", "3.11", true},
+	}
+	for _, tt := range tests {
+		if got := matchInterpreterVersion(tt.version, tt.want); got != tt.match {
+			t.Errorf("Want version %s match %s %v, got %v", tt.version, tt.want, tt.match, got)
+		}
+	}
+}
+
+func TestHashFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "package.json")
+	b := filepath.Join(dir, "package-lock.json")
+	if err := os.WriteFile(a, []byte(`{"name":"plugin"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte(`{"lockfileVersion":3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h1, err := hashFiles(a, b)
+	assert.NoError(t, err)
+	h2, _ := hashFiles(a, b)
+	assert.Equal(t, h1, h2)
+
+	if err := os.WriteFile(b, []byte(`{"lockfileVersion":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h3, _ := hashFiles(a, b)
+	assert.NotEqual(t, h1, h3, "Want hash to change with lockfile")
+
+	_, err = hashFiles(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -4,7 +4,22 @@
 
 package harness
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/drone/plugin/plugin/internal/versions"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v2"
+)
+
+//go:generate go run ../../scripts/harness.go -registry $HARNESS_PLUGIN_REGISTRY
 
 // Lookup returns the repository and commit associated
 // with the named step and version.
@@ -19,16 +34,23 @@ func Lookup(name, version string) (repo string, ref string, commit string, ok bo
 		return plugin_.repo, "", "", ok
 	}
 
-	// TODO(bradrydzeski) we should be able to use smart
-	// matching based on semantic versioning. For example,
-	// if the user specifies version '1' we should be able
-	// to match to the latest 1.x.x release.
-
-	version_, ok := plugin_.versions[version]
+	// resolve the version range (e.g. ^1, ~1.2, 1.x or
+	// latest) to the latest matching release.
+	var available []string
+	for v := range plugin_.versions {
+		available = append(available, v)
+	}
+	resolved, ok := versions.Resolve(version, available)
 	if !ok {
 		return
 	}
-	return plugin_.repo, "", version_, ok
+	commit = plugin_.versions[resolved]
+	slog.Info("resolved plugin version",
+		slog.String("name", name),
+		slog.String("version", version),
+		slog.String("resolved", resolved),
+		slog.String("commit", commit))
+	return plugin_.repo, "", commit, ok
 }
 
 // ParseLookup parses the step string and returns the
@@ -72,6 +94,76 @@ func ParseLookup(s string) (repo string, ref string, commit string, ok bool) {
 	return Lookup(s, "")
 }
 
+// LoadIndex loads an additional plugin index from a local
+// file or url, in yaml or json format. Plugins in the index
+// take precedence over the builtin plugins with the same name.
+//
+//	plugins:
+//	- name: webhook
+//	  repo: https://github.com/drone-plugins/drone-webhook.git
+//	  versions:
+//	    1.0.0: b83c0042154f9c5d4bc3c42a847c3c287c12a505
+func LoadIndex(ctx context.Context, path string) error {
+	raw, err := readIndex(ctx, path)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("cannot read plugin index: %s", path))
+	}
+
+	// yaml is a superset of json, so the yaml parser is
+	// used for both formats.
+	out := struct {
+		Plugins []struct {
+			Name     string            `yaml:"name"`
+			Repo     string            `yaml:"repo"`
+			Versions map[string]string `yaml:"versions"`
+		} `yaml:"plugins"`
+	}{}
+	if err := yaml.Unmarshal(raw, &out); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("cannot parse plugin index: %s", path))
+	}
+
+	for _, p := range out.Plugins {
+		if p.Name == "" || p.Repo == "" {
+			return fmt.Errorf("invalid plugin index: %s: name and repo are required", path)
+		}
+		if _, ok := index[p.Name]; ok {
+			slog.Debug("plugin index overrides builtin plugin", slog.String("name", p.Name))
+		}
+		index[p.Name] = plugin{
+			name:     p.Name,
+			repo:     p.Repo,
+			versions: p.Versions,
+		}
+	}
+	slog.Debug("loaded plugin index", slog.String("path", path), slog.Int("plugins", len(out.Plugins)))
+	return nil
+}
+
+// helper function reads the plugin index from a local file
+// or url.
+func readIndex(ctx context.Context, path string) ([]byte, error) {
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		return os.ReadFile(path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
 type plugin struct {
 	name     string
 	repo     string
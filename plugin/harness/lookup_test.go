@@ -4,7 +4,14 @@
 
 package harness
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestParseLookup(t *testing.T) {
 	tests := []struct {
@@ -64,3 +71,135 @@ func TestParseLookup(t *testing.T) {
 		}
 	}
 }
+
+func TestLookup_Range(t *testing.T) {
+	saved := index
+	defer func() { index = saved }()
+	index = map[string]plugin{
+		"deploy": {
+			name: "deploy",
+			repo: "https://git.example.com/platform/deploy.git",
+			versions: map[string]string{
+				"1.0.0": "1000000000000000000000000000000000000000",
+				"1.2.0": "1200000000000000000000000000000000000000",
+				"1.2.3": "1230000000000000000000000000000000000000",
+				"2.0.0": "2000000000000000000000000000000000000000",
+			},
+		},
+	}
+
+	tests := []struct {
+		version string
+		commit  string
+		ok      bool
+	}{
+		{version: "1.2.0", commit: "1200000000000000000000000000000000000000", ok: true},
+		{version: "1", commit: "1230000000000000000000000000000000000000", ok: true},
+		{version: "^1", commit: "1230000000000000000000000000000000000000", ok: true},
+		{version: "~1.0", commit: "1000000000000000000000000000000000000000", ok: true},
+		{version: "1.x", commit: "1230000000000000000000000000000000000000", ok: true},
+		{version: "latest", commit: "2000000000000000000000000000000000000000", ok: true},
+		{version: "^3", commit: "", ok: false},
+	}
+	for _, test := range tests {
+		_, _, commit, ok := Lookup("deploy", test.version)
+		if got, want := ok, test.ok; got != want {
+			t.Errorf("Expect version %s found %v, got %v", test.version, want, got)
+		}
+		if got, want := commit, test.commit; got != want {
+			t.Errorf("Expect commit %s for version %s, got %s", want, test.version, got)
+		}
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	saved := index
+	defer func() { index = saved }()
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "yaml",
+			data: `
+plugins:
+- name: deploy
+  repo: https://git.example.com/platform/deploy.git
+  versions:
+    1.0.0: 0a1b2c3d4e5f60718293a4b5c6d7e8f901234567
+- name: webhook
+  repo: https://git.example.com/mirrors/drone-webhook.git
+`,
+		},
+		{
+			name: "json",
+			data: `{"plugins": [
+  {"name": "deploy", "repo": "https://git.example.com/platform/deploy.git", "versions": {"1.0.0": "0a1b2c3d4e5f60718293a4b5c6d7e8f901234567"}},
+  {"name": "webhook", "repo": "https://git.example.com/mirrors/drone-webhook.git"}
+]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index = map[string]plugin{}
+			for k, v := range saved {
+				index[k] = v
+			}
+
+			path := filepath.Join(t.TempDir(), "index."+test.name)
+			if err := os.WriteFile(path, []byte(test.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := LoadIndex(context.Background(), path); err != nil {
+				t.Fatal(err)
+			}
+
+			repo, _, commit, ok := ParseLookup("deploy@1.0.0")
+			if !ok {
+				t.Fatalf("Expect plugin deploy found in index")
+			}
+			if got, want := repo, "https://git.example.com/platform/deploy.git"; got != want {
+				t.Errorf("Expect repository %s, got %s", want, got)
+			}
+			if got, want := commit, "0a1b2c3d4e5f60718293a4b5c6d7e8f901234567"; got != want {
+				t.Errorf("Expect commit %s, got %s", want, got)
+			}
+
+			// plugins in the index take precedence over the
+			// builtin plugins.
+			repo, _, _, _ = ParseLookup("webhook")
+			if got, want := repo, "https://git.example.com/mirrors/drone-webhook.git"; got != want {
+				t.Errorf("Expect repository %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestLoadIndex_URL(t *testing.T) {
+	saved := index
+	defer func() { index = saved }()
+	index = map[string]plugin{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yml" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte("plugins:\n- name: deploy\n  repo: https://git.example.com/platform/deploy.git\n"))
+	}))
+	defer srv.Close()
+
+	if err := LoadIndex(context.Background(), srv.URL+"/index.yml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, ok := ParseLookup("deploy"); !ok {
+		t.Errorf("Expect plugin deploy found in index")
+	}
+	if err := LoadIndex(context.Background(), srv.URL+"/missing.yml"); err == nil {
+		t.Errorf("Expect error when index not found")
+	}
+	if err := LoadIndex(context.Background(), filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Errorf("Expect error when index file not found")
+	}
+}
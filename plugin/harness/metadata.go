@@ -1,14 +1,26 @@
 package harness
 
 import (
+	"bufio"
 	"fmt"
-	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"text/template"
 
 	"github.com/pkg/errors"
 )
 
+// platform detection files. These are variables so that
+// they can be overridden for testing purposes.
+var (
+	osReleasePaths = []string{"/etc/os-release", "/usr/lib/os-release"}
+	cpuinfoPath    = "/proc/cpuinfo"
+	muslPattern    = "/lib/ld-musl-*.so.1"
+)
+
 // Metadata implements a source url generator that uses a template engine
 // to generate url from give metadata.
 type Metadata struct {
@@ -16,14 +28,24 @@ type Metadata struct {
 	funcMap template.FuncMap
 }
 
-// NewMetadata creates a new source Generator.
-func NewMetadata(tmpl string, ref string) *Metadata {
+// NewMetadata creates a new source Generator. The ref and sha
+// identify the plugin version, and the environment is used to
+// resolve env lookups in the template.
+func NewMetadata(tmpl, ref, sha string, environ []string) *Metadata {
 	return &Metadata{
 		tmpl: tmpl,
 		funcMap: template.FuncMap{
-			"arch":    func() string { return runtime.GOARCH },
-			"os":      func() string { return runtime.GOOS },
-			"release": releaseFunc(ref),
+			"arch":       func() string { return runtime.GOARCH },
+			"os":         func() string { return runtime.GOOS },
+			"release":    releaseFunc(ref),
+			"ref":        func() string { return ref },
+			"sha":        func() string { return sha },
+			"ext":        extFunc,
+			"libc":       libcFunc,
+			"armVariant": armVariantFunc,
+			"osVersion":  osVersionFunc,
+			"distro":     distroFunc,
+			"env":        envFunc(environ),
 		},
 	}
 }
@@ -43,6 +65,22 @@ func (g *Metadata) Generate() (string, error) {
 	return sb.String(), nil
 }
 
+// helper function overrides the os and arch used to generate
+// the url, so that the url can be generated for a platform
+// other than the host. Other host properties (e.g. libc) are
+// not overridden.
+func (g *Metadata) forPlatform(goos, goarch string) *Metadata {
+	g.funcMap["os"] = func() string { return goos }
+	g.funcMap["arch"] = func() string { return goarch }
+	g.funcMap["ext"] = func() string {
+		if goos == "windows" {
+			return ".exe"
+		}
+		return ""
+	}
+	return g
+}
+
 func releaseFunc(ref string) func() string {
 	return func() string {
 		if strings.HasPrefix(ref, "refs/tags/") {
@@ -51,3 +89,119 @@ func releaseFunc(ref string) func() string {
 		return "latest"
 	}
 }
+
+// helper function returns the executable file extension.
+func extFunc() string {
+	if runtime.GOOS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+// helper function returns the c library used by the host,
+// musl or glibc. An empty string is returned if the host is
+// not linux.
+func libcFunc() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	if matches, _ := filepath.Glob(muslPattern); len(matches) > 0 {
+		return "musl"
+	}
+	return "glibc"
+}
+
+// helper function returns the arm architecture variant (e.g.
+// v6, v7, v8). An empty string is returned if the host is not
+// an arm host.
+func armVariantFunc() string {
+	if runtime.GOARCH == "arm64" {
+		return "v8"
+	}
+	if runtime.GOARCH != "arm" {
+		return ""
+	}
+
+	f, err := os.Open(cpuinfoPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "CPU architecture" {
+			continue
+		}
+		// the architecture is a number, optionally followed
+		// by a suffix (e.g. 7 or 5TEJ).
+		value = strings.TrimSpace(value)
+		if len(value) > 0 && value[0] >= '0' && value[0] <= '9' {
+			return "v" + value[:1]
+		}
+	}
+	return ""
+}
+
+// helper function returns the operating system version. On
+// linux this is the os-release version id.
+func osVersionFunc() string {
+	switch runtime.GOOS {
+	case "linux":
+		return osRelease()["VERSION_ID"]
+	case "darwin":
+		out, err := exec.Command("sw_vers", "-productVersion").Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	default:
+		return ""
+	}
+}
+
+// helper function returns the linux distribution identifier
+// (e.g. ubuntu, alpine, rhel) from os-release.
+func distroFunc() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	return osRelease()["ID"]
+}
+
+// helper function returns a template function that returns
+// the environment variable value.
+func envFunc(environ []string) func(string) string {
+	return func(key string) string {
+		for i := len(environ) - 1; i >= 0; i-- {
+			if k, v, ok := strings.Cut(environ[i], "="); ok && k == key {
+				return v
+			}
+		}
+		return ""
+	}
+}
+
+// helper function parses the os-release file.
+func osRelease() map[string]string {
+	out := map[string]string{}
+	for _, path := range osReleasePaths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !ok || strings.HasPrefix(key, "#") {
+				continue
+			}
+			out[key] = strings.Trim(value, `"'`)
+		}
+		break
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata(t *testing.T) {
+	environ := []string{"PLUGIN_VARIANT=slim", "DRONE_REPO=octocat/hello"}
+	tests := []struct {
+		tmpl, ref, expected string
+	}{
+		{
+			tmpl:     "https://example.com/{{ release }}/plugin-{{ os }}-{{ arch }}{{ ext }}",
+			ref:      "refs/tags/v1.0.0",
+			expected: "https://example.com/v1.0.0/plugin-" + runtime.GOOS + "-" + runtime.GOARCH + extFunc(),
+		},
+		{
+			tmpl:     "https://example.com/{{ release }}/plugin",
+			ref:      "refs/heads/main",
+			expected: "https://example.com/latest/plugin",
+		},
+		{
+			// verify characters are not html escaped.
+			tmpl:     "https://example.com/plugin?a=1&b=c+d",
+			expected: "https://example.com/plugin?a=1&b=c+d",
+		},
+		{
+			tmpl:     "https://example.com/{{ sha }}/plugin-{{ env \"PLUGIN_VARIANT\" }}{{ env \"PLUGIN_MISSING\" }}",
+			expected: "https://example.com/3f2b1c/plugin-slim",
+		},
+		{
+			tmpl:     "{{ ref }}",
+			ref:      "refs/heads/main",
+			expected: "refs/heads/main",
+		},
+	}
+	for _, tt := range tests {
+		got, err := NewMetadata(tt.tmpl, tt.ref, "3f2b1c", environ).Generate()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, got)
+	}
+
+	_, err := NewMetadata("{{ unknown }}", "", "", nil).Generate()
+	assert.Error(t, err)
+}
+
+func TestMetadata_Distro(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux required")
+	}
+
+	path := filepath.Join(t.TempDir(), "os-release")
+	if err := os.WriteFile(path, []byte("NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.18.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := osReleasePaths
+	osReleasePaths = []string{path}
+	defer func() { osReleasePaths = saved }()
+
+	got, err := NewMetadata("{{ distro }}-{{ osVersion }}", "", "", nil).Generate()
+	assert.NoError(t, err)
+	assert.Equal(t, "alpine-3.18.4", got)
+}
+
+func TestMetadata_Libc(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("linux required")
+	}
+
+	dir := t.TempDir()
+	saved := muslPattern
+	defer func() { muslPattern = saved }()
+
+	muslPattern = filepath.Join(dir, "ld-musl-*.so.1")
+	assert.Equal(t, "glibc", libcFunc())
+
+	if err := os.WriteFile(filepath.Join(dir, "ld-musl-x86_64.so.1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "musl", libcFunc())
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/drone/plugin/cache"
+	"github.com/drone/plugin/plugin/internal/process"
+	"golang.org/x/exp/slog"
+)
+
+// node package manager lockfiles, in order of detection.
+var nodeLockfiles = []struct {
+	manager  string
+	lockfile string
+}{
+	{"pnpm", "pnpm-lock.yaml"},
+	{"yarn", "yarn.lock"},
+	{"npm", "package-lock.json"},
+}
+
+// runNodeExecutable installs the package dependencies and
+// executes the node entry file.
+func (e *Execer) runNodeExecutable(ctx context.Context, node *Node) error {
+	if node.Entry == "" {
+		return fmt.Errorf("node entry is not set")
+	}
+	version, err := e.interpreterVersion(ctx, "node", node.Version, "node", "--version")
+	if err != nil {
+		return err
+	}
+	if err := e.installNodeModules(ctx, node, version); err != nil {
+		return err
+	}
+
+	if e.DownloadOnly {
+		slog.Info("Download only flag is set. Not executing the plugin")
+		return nil
+	}
+
+	args, err := expandArgs(node.Args, e.Environ)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(e.Source, node.Entry)
+	slog.Debug("node run", slog.String("file", path), slog.String("version", version))
+
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
+	cmd := process.Command(ctx, "node", append([]string{path}, args...)...)
+	return done(runCmds(ctx, []*exec.Cmd{cmd}, e.Environ, e.Workdir, e.Stdout, e.Stderr))
+}
+
+// installNodeModules installs the package dependencies into
+// the cache, keyed by the package manager, node version and
+// lockfile hash, and links the node_modules directory in the
+// source directory to the cache.
+func (e *Execer) installNodeModules(ctx context.Context, node *Node, version string) error {
+	pkg := filepath.Join(e.Source, "package.json")
+	if !exists(pkg) {
+		slog.Debug("package.json not found, skipping install")
+		return nil
+	}
+
+	manager, lockfile := nodePackageManager(e.Source, node.Install)
+	files := []string{pkg}
+	if lockfile != "" {
+		files = append(files, filepath.Join(e.Source, lockfile))
+	}
+	hash, err := hashFiles(files...)
+	if err != nil {
+		return err
+	}
+	key := cache.GetKeyName(strings.Join([]string{"node", manager, version, hash}, "\n"))
+
+	installFn := func() error {
+		for _, file := range files {
+			if err := copyFile(file, key); err != nil {
+				return err
+			}
+		}
+		slog.Debug("node install", slog.String("manager", manager), slog.String("lockfile", lockfile))
+
+		ctx, done := process.WithTimeout(ctx, "build", e.BuildTimeout)
+		cmd := command(ctx, nodeInstallArgs(manager, lockfile != ""))
+		env := append(e.Environ[:len(e.Environ):len(e.Environ)], npm().env...)
+		return done(runCmds(ctx, []*exec.Cmd{cmd}, env, key, e.Stdout, e.Stderr))
+	}
+	if err := cache.Add(key, installFn); err != nil {
+		return err
+	}
+
+	modules := filepath.Join(key, "node_modules")
+	link := filepath.Join(e.Source, "node_modules")
+	if info, err := os.Lstat(link); err == nil {
+		// node modules vendored in the source directory
+		// are used as-is.
+		if info.Mode()&os.ModeSymlink == 0 {
+			slog.Debug("node_modules exists, skipping link")
+			return nil
+		}
+		os.Remove(link)
+	}
+	if err := os.Symlink(modules, link); err != nil {
+		// fallback to the node path if symlinks are not
+		// supported (e.g. windows without privileges).
+		slog.Debug("cannot link node_modules, using NODE_PATH", "error", err)
+		e.Environ = append(e.Environ, "NODE_PATH="+modules)
+	}
+	return nil
+}
+
+// helper function returns the node package manager and the
+// lockfile. The package manager is detected by lockfile if
+// not provided.
+func nodePackageManager(dir, manager string) (string, string) {
+	for _, v := range nodeLockfiles {
+		if manager != "" && manager != v.manager {
+			continue
+		}
+		if exists(filepath.Join(dir, v.lockfile)) {
+			return v.manager, v.lockfile
+		}
+	}
+	if manager == "" {
+		manager = "npm"
+	}
+	return manager, ""
+}
+
+// helper function returns the package manager install
+// command. Development dependencies are not installed, and
+// the lockfile is not updated.
+func nodeInstallArgs(manager string, locked bool) []string {
+	switch manager {
+	case "yarn":
+		if locked {
+			return []string{"yarn", "install", "--production", "--frozen-lockfile"}
+		}
+		return []string{"yarn", "install", "--production"}
+	case "pnpm":
+		if locked {
+			return []string{"pnpm", "install", "--prod", "--frozen-lockfile"}
+		}
+		return []string{"pnpm", "install", "--prod"}
+	default:
+		if locked {
+			return []string{"npm", "ci", "--omit=dev"}
+		}
+		return []string{"npm", "install", "--omit=dev"}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodePackageManager(t *testing.T) {
+	dir := t.TempDir()
+
+	manager, lockfile := nodePackageManager(dir, "")
+	assert.Equal(t, "npm", manager)
+	assert.Empty(t, lockfile)
+
+	if err := os.WriteFile(filepath.Join(dir, "yarn.lock"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	manager, lockfile = nodePackageManager(dir, "")
+	assert.Equal(t, "yarn", manager)
+	assert.Equal(t, "yarn.lock", lockfile)
+
+	manager, lockfile = nodePackageManager(dir, "pnpm")
+	assert.Equal(t, "pnpm", manager)
+	assert.Empty(t, lockfile)
+}
+
+func TestNodeInstallArgs(t *testing.T) {
+	assert.Equal(t, []string{"npm", "ci", "--omit=dev"}, nodeInstallArgs("npm", true))
+	assert.Equal(t, []string{"npm", "install", "--omit=dev"}, nodeInstallArgs("", false))
+	assert.Equal(t, []string{"yarn", "install", "--production", "--frozen-lockfile"}, nodeInstallArgs("yarn", true))
+	assert.Equal(t, []string{"pnpm", "install", "--prod"}, nodeInstallArgs("pnpm", false))
+}
+
+func TestRunNodeExecutable(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node required")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte(`console.log("hello " + process.argv[2])`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := new(bytes.Buffer)
+	e := &Execer{
+		Source:  dir,
+		Workdir: dir,
+		Environ: append(os.Environ(), "PLUGIN_NAME=world"),
+		Stdout:  stdout,
+		Stderr:  io.Discard,
+	}
+	err := e.runNodeExecutable(context.Background(), &Node{Entry: "index.js", Args: []string{"${PLUGIN_NAME}"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world\n", stdout.String())
+
+	err = e.runNodeExecutable(context.Background(), &Node{Entry: "index.js", Version: "0.1"})
+	assert.ErrorContains(t, err, "does not match required version 0.1")
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/drone/plugin/plugin/internal/encoder"
+	"github.com/drone/plugin/plugin/internal/environ"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slog"
+)
+
+// outputEnv is the environment variable that provides the
+// plugin with the path of the file to write outputs. The
+// file may be written in dotenv or json format.
+const outputEnv = "DRONE_PLUGIN_OUTPUT"
+
+// createOutputFile creates the plugin output file and adds
+// the file path to the plugin environment. The returned
+// function removes the output file.
+func (e *Execer) createOutputFile() (func(), error) {
+	dir, err := os.MkdirTemp("", "plugin-output")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create output directory")
+	}
+	path := filepath.Join(dir, "output.env")
+	if err := os.WriteFile(path, nil, 0666); err != nil {
+		os.RemoveAll(dir)
+		return nil, errors.Wrap(err, "failed to create output file")
+	}
+
+	e.outputPath = path
+	e.Environ = append(e.Environ, fmt.Sprintf("%s=%s", outputEnv, path))
+	return func() { os.RemoveAll(dir) }, nil
+}
+
+// saveOutputs reads the plugin output file and writes the
+// declared outputs to the step output file. Secret outputs
+// are written to the secret output file. Output names are
+// case insensitive, consistent with the output environment
+// variables exposed to the post hooks, and are written with
+// the declared name.
+func (e *Execer) saveOutputs(outputs []*Output) error {
+	values, err := readOutputs(e.outputPath)
+	if err != nil {
+		return err
+	}
+
+	plain := map[string]string{}
+	secret := map[string]string{}
+	for _, out := range outputs {
+		if out == nil || out.Name == "" {
+			continue
+		}
+		key, val, ok := lookupOutput(values, out.Name)
+		if !ok {
+			slog.Debug("output not set", slog.String("name", out.Name))
+			continue
+		}
+		delete(values, key)
+		if out.Secret {
+			secret[out.Name] = val
+		} else {
+			plain[out.Name] = val
+		}
+	}
+
+	// outputs that are not declared in the plugin.yml
+	// are not exported.
+	for name := range values {
+		slog.Warn("ignoring undeclared output", slog.String("name", name))
+	}
+
+	if len(plain) > 0 {
+		if e.OutputFile == "" {
+			slog.Warn("output file not set, skipping outputs")
+		} else if err := environ.WriteFile(e.OutputFile, plain); err != nil {
+			return errors.Wrap(err, "failed to write outputs")
+		}
+	}
+	if len(secret) > 0 {
+		if e.SecretOutputFile == "" {
+			slog.Warn("secret output file not set, skipping secret outputs")
+		} else if err := environ.WriteFile(e.SecretOutputFile, secret); err != nil {
+			return errors.Wrap(err, "failed to write secret outputs")
+		}
+	}
+	return nil
+}
+
+// helper function returns the output value with the name.
+// An exact match takes precedence over a case insensitive
+// match.
+func lookupOutput(values map[string]string, name string) (string, string, bool) {
+	if val, ok := values[name]; ok {
+		return name, val, true
+	}
+	for key, val := range values {
+		if strings.EqualFold(key, name) {
+			return key, val, true
+		}
+	}
+	return "", "", false
+}
+
+// readOutputs reads the plugin output file. The file is
+// parsed as json if it contains a json object, otherwise
+// it is parsed in dotenv format.
+func readOutputs(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read output file")
+	}
+
+	trimmed := bytes.TrimSpace(raw)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		in := map[string]interface{}{}
+		if err := json.Unmarshal(trimmed, &in); err != nil {
+			return nil, errors.Wrap(err, "failed to parse json output file")
+		}
+		out := map[string]string{}
+		for k, v := range in {
+			if v == nil {
+				out[k] = ""
+			} else {
+				out[k] = encoder.Encode(v)
+			}
+		}
+		return out, nil
+	}
+
+	out, err := godotenv.Unmarshal(string(raw))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse output file")
+	}
+	return out, nil
+}
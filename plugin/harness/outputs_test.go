@@ -0,0 +1,85 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOutputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected map[string]string
+	}{
+		{
+			name:     "dotenv",
+			data:     "VERSION=1.0.0\nDIGEST=\"sha256:abc\"\n",
+			expected: map[string]string{"VERSION": "1.0.0", "DIGEST": "sha256:abc"},
+		},
+		{
+			name:     "json",
+			data:     `{"version": "1.0.0", "count": 3, "ok": true, "tags": ["a", "b"]}`,
+			expected: map[string]string{"version": "1.0.0", "count": "3", "ok": "true", "tags": "a,b"},
+		},
+		{
+			name:     "empty",
+			data:     "",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "output.env")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readOutputs(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestSaveOutputs(t *testing.T) {
+	dir := t.TempDir()
+	e := &Execer{
+		OutputFile:       filepath.Join(dir, "output.env"),
+		SecretOutputFile: filepath.Join(dir, "secret.env"),
+	}
+	if _, err := e.createOutputFile(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(e.outputPath))
+
+	data := "VERSION=1.0.0\nBUILD=007\nTOKEN=secret\nUNDECLARED=value\n"
+	if err := os.WriteFile(e.outputPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := []*Output{
+		{Name: "VERSION"},
+		{Name: "BUILD"},
+		{Name: "TOKEN", Secret: true},
+		{Name: "MISSING"},
+	}
+	if err := e.saveOutputs(outputs); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := godotenv.Read(e.OutputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"VERSION": "1.0.0", "BUILD": "007"}, plain)
+
+	secret, err := godotenv.Read(e.SecretOutputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"TOKEN": "secret"}, secret)
+}
@@ -24,10 +24,10 @@ func TestParseFile(t *testing.T) {
 				wantSource := "https://github.com/drone-plugins/plugin/releases/download/{{ release }}/plugin-{{ os }}-{{ arch }}.zst"
 				wantFallback := ""
 
-				if got := s.Run.Binary.Source; got != wantSource {
+				if got := s.Run[0].Binary.Source; got != wantSource {
 					t.Errorf("Expected source %q, got %q", wantSource, got)
 				}
-				if got := s.Run.Binary.FallbackSource; got != wantFallback {
+				if got := s.Run[0].Binary.FallbackSource; got != wantFallback {
 					t.Errorf("Expected fallback source %q, got %q", wantFallback, got)
 				}
 			},
@@ -40,10 +40,10 @@ func TestParseFile(t *testing.T) {
 				wantSource := "https://github.com/drone-plugins/plugin/releases/download/{{ release }}/plugin-{{ os }}-{{ arch }}.zst"
 				wantFallback := "https://mirror.example.com/plugin/releases/download/{{ release }}/plugin-{{ os }}-{{ arch }}.zst"
 
-				if got := s.Run.Binary.Source; got != wantSource {
+				if got := s.Run[0].Binary.Source; got != wantSource {
 					t.Errorf("Expected source %q, got %q", wantSource, got)
 				}
-				if got := s.Run.Binary.FallbackSource; got != wantFallback {
+				if got := s.Run[0].Binary.FallbackSource; got != wantFallback {
 					t.Errorf("Expected fallback source %q, got %q", wantFallback, got)
 				}
 			},
@@ -79,10 +79,213 @@ run:
 		t.Error(err)
 		return
 	}
-	if got, want := out.Run.Binary.Source, "https://github.com/drone-plugins/drone-meltwater-cache/releases/download/{{ release }}/plugin-{{ os }}-{{ arch }}.zst"; got != want {
+	if got, want := out.Run[0].Binary.Source, "https://github.com/drone-plugins/drone-meltwater-cache/releases/download/{{ release }}/plugin-{{ os }}-{{ arch }}.zst"; got != want {
 		t.Errorf("Want source URL %q, got %q", want, got)
 	}
-	if got, want := out.Run.Binary.FallbackSource, "https://backup-mirror.example.com/drone-plugins/drone-meltwater-cache/releases/download/{{ release }}/plugin-{{ os }}-{{ arch }}.zst"; got != want {
+	if got, want := out.Run[0].Binary.FallbackSource, "https://backup-mirror.example.com/drone-plugins/drone-meltwater-cache/releases/download/{{ release }}/plugin-{{ os }}-{{ arch }}.zst"; got != want {
 		t.Errorf("Want fallback source URL %q, got %q", want, got)
 	}
 }
+
+func TestParseBinaryPlatforms(t *testing.T) {
+	yaml := `
+run:
+  binary:
+    platforms:
+      linux/amd64:
+        source: https://github.com/drone-plugins/plugin/releases/download/{{ release }}/plugin_Linux_x86_64.zst
+        checksum: sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+      darwin/*: https://github.com/drone-plugins/plugin/releases/download/{{ release }}/plugin_Darwin_all.zst
+`
+	out, err := parseString(yaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	linux := out.Run[0].Binary.Platforms["linux/amd64"]
+	if linux == nil {
+		t.Fatalf("Want linux/amd64 platform source")
+	}
+	if got, want := linux.Checksum, "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"; got != want {
+		t.Errorf("Want checksum %q, got %q", want, got)
+	}
+	darwin := out.Run[0].Binary.Platforms["darwin/*"]
+	if darwin == nil {
+		t.Fatalf("Want darwin/* platform source")
+	}
+	if got, want := darwin.Source, "https://github.com/drone-plugins/plugin/releases/download/{{ release }}/plugin_Darwin_all.zst"; got != want {
+		t.Errorf("Want source URL %q, got %q", want, got)
+	}
+}
+
+func TestParseGo(t *testing.T) {
+	yaml := `
+run:
+  go:
+    module: github.com/org/tool/cmd/x@v1.2.3
+    trimpath: true
+    ldflags: -s -w
+    tags: [ netgo ]
+    cgo: false
+`
+	out, err := parseString(yaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got, want := out.Run[0].Go.Module, "github.com/org/tool/cmd/x@v1.2.3"; got != want {
+		t.Errorf("Want module %q, got %q", want, got)
+	}
+	if !out.Run[0].Go.Trimpath {
+		t.Errorf("Want trimpath enabled")
+	}
+	if got, want := out.Run[0].Go.Ldflags, "-s -w"; got != want {
+		t.Errorf("Want ldflags %q, got %q", want, got)
+	}
+	if got := out.Run[0].Go.Tags; len(got) != 1 || got[0] != "netgo" {
+		t.Errorf("Want tags [netgo], got %v", got)
+	}
+	if got := out.Run[0].Go.Cgo; got == nil || *got {
+		t.Errorf("Want cgo disabled")
+	}
+}
+
+func TestParseNodePython(t *testing.T) {
+	yaml := `
+run:
+  node:
+    entry: dist/index.js
+    version: "18"
+    install: pnpm
+  python:
+    module: plugin
+    requirements: requirements/prod.txt
+`
+	out, err := parseString(yaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got, want := out.Run[0].Node.Entry, "dist/index.js"; got != want {
+		t.Errorf("Want node entry %q, got %q", want, got)
+	}
+	if got, want := out.Run[0].Node.Version, "18"; got != want {
+		t.Errorf("Want node version %q, got %q", want, got)
+	}
+	if got, want := out.Run[0].Node.Install, "pnpm"; got != want {
+		t.Errorf("Want node install %q, got %q", want, got)
+	}
+	if got, want := out.Run[0].Python.Module, "plugin"; got != want {
+		t.Errorf("Want python module %q, got %q", want, got)
+	}
+	if got, want := out.Run[0].Python.Requirements, "requirements/prod.txt"; got != want {
+		t.Errorf("Want python requirements %q, got %q", want, got)
+	}
+}
+
+func TestParseWhen(t *testing.T) {
+	yaml := `
+deps:
+  - apt: [ curl ]
+  - when:
+      arch: arm64
+      distro: [ ubuntu, debian ]
+    apt: [ qemu-user-static ]
+run:
+  - when:
+      os: windows
+    pwsh:
+      path: run.ps1
+  - when:
+      env:
+        PLUGIN_MODE: docker
+    docker:
+      image: plugins/webhook
+  - bash:
+      path: run.sh
+`
+	out, err := parseString(yaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got, want := len(out.Deps), 2; got != want {
+		t.Fatalf("Want %d deps blocks, got %d", want, got)
+	}
+	if got := out.Deps[1].When; got == nil || len(got.Arch) != 1 || got.Arch[0] != "arm64" || len(got.Distro) != 2 {
+		t.Errorf("Want deps conditions parsed, got %+v", got)
+	}
+	if got, want := len(out.Run), 3; got != want {
+		t.Fatalf("Want %d run blocks, got %d", want, got)
+	}
+	if got, want := out.Run[1].When.Env["PLUGIN_MODE"], "docker"; got != want {
+		t.Errorf("Want env condition %q, got %q", want, got)
+	}
+	if got, want := out.Run[2].Bash.Path, "run.sh"; got != want {
+		t.Errorf("Want bash path %q, got %q", want, got)
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	yaml := `
+inputs:
+  - name: token
+    env: GITHUB_TOKEN
+env:
+  GITHUB_REPOSITORY: ${DRONE_REPO}
+`
+	out, err := parseString(yaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got, want := out.Inputs[0].Env, "GITHUB_TOKEN"; got != want {
+		t.Errorf("Want input env %q, got %q", want, got)
+	}
+	if got, want := out.Env["GITHUB_REPOSITORY"], "${DRONE_REPO}"; got != want {
+		t.Errorf("Want env %q, got %q", want, got)
+	}
+}
+
+func TestParseInputs(t *testing.T) {
+	yaml := `
+inputs:
+  - name: url
+    required: true
+  - name: method
+    default: POST
+    enum: [ GET, POST ]
+  - name: token
+    secret: true
+  - name: retries
+    type: integer
+    default: 3
+run:
+  bash:
+    path: run.sh
+`
+	out, err := parseString(yaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got, want := len(out.Inputs), 4; got != want {
+		t.Errorf("Want %d inputs, got %d", want, got)
+		return
+	}
+	if !out.Inputs[0].Required {
+		t.Errorf("Want input url required")
+	}
+	if got, want := out.Inputs[1].Default, "POST"; got != want {
+		t.Errorf("Want input method default %v, got %v", want, got)
+	}
+	if got, want := len(out.Inputs[1].Enum), 2; got != want {
+		t.Errorf("Want input method with %d enum values, got %d", want, got)
+	}
+	if !out.Inputs[2].Secret {
+		t.Errorf("Want input token secret")
+	}
+	if got, want := out.Inputs[3].Default, 3; got != want {
+		t.Errorf("Want input retries default %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/drone/plugin/cache"
+	"github.com/drone/plugin/plugin/internal/process"
+	"golang.org/x/exp/slog"
+)
+
+// runPythonExecutable installs the requirements into a
+// virtual environment and executes the python entry script
+// or module.
+func (e *Execer) runPythonExecutable(ctx context.Context, py *Python) error {
+	var entry []string
+	switch {
+	case py.Module != "":
+		entry = []string{"-m", py.Module}
+	case py.Entry != "":
+		entry = []string{filepath.Join(e.Source, py.Entry)}
+	default:
+		return fmt.Errorf("python entry or module is not set")
+	}
+
+	bin := "python3"
+	if _, err := exec.LookPath(bin); err != nil {
+		bin = "python"
+	}
+	version, err := e.interpreterVersion(ctx, "python", py.Version, bin, "--version")
+	if err != nil {
+		return err
+	}
+	python, err := e.installPythonVenv(ctx, py, bin, version)
+	if err != nil {
+		return err
+	}
+
+	if e.DownloadOnly {
+		slog.Info("Download only flag is set. Not executing the plugin")
+		return nil
+	}
+
+	args, err := expandArgs(py.Args, e.Environ)
+	if err != nil {
+		return err
+	}
+	slog.Debug("python run", slog.String("entry", strings.Join(entry, " ")), slog.String("version", version))
+
+	// the source directory is added to the python path so
+	// that the entry module can be imported.
+	pythonpath := e.Source
+	for _, v := range e.Environ {
+		if strings.HasPrefix(v, "PYTHONPATH=") && v != "PYTHONPATH=" {
+			pythonpath = pythonpath + string(os.PathListSeparator) + strings.TrimPrefix(v, "PYTHONPATH=")
+		}
+	}
+	env := append(e.Environ[:len(e.Environ):len(e.Environ)], "PYTHONPATH="+pythonpath)
+
+	ctx, done := process.WithTimeout(ctx, "run", e.RunTimeout)
+	cmd := process.Command(ctx, python, append(entry, args...)...)
+	return done(runCmds(ctx, []*exec.Cmd{cmd}, env, e.Workdir, e.Stdout, e.Stderr))
+}
+
+// installPythonVenv installs the requirements into a virtual
+// environment in the cache, keyed by the python version and
+// requirements hash, and returns the python interpreter path.
+// The host interpreter is returned if the plugin does not
+// have requirements.
+func (e *Execer) installPythonVenv(ctx context.Context, py *Python, bin, version string) (string, error) {
+	requirements := pythonRequirements(e.Source, py.Requirements)
+	if requirements == "" {
+		slog.Debug("python requirements not found, skipping install")
+		return bin, nil
+	}
+	hash, err := hashFiles(requirements)
+	if err != nil {
+		return "", err
+	}
+
+	// a pyproject installs the plugin source into the
+	// virtual environment, so the source directory is
+	// included in the cache key.
+	parts := []string{"python", version, hash}
+	install := []string{"install", "-r", requirements}
+	if filepath.Base(requirements) == "pyproject.toml" {
+		parts = append(parts, e.Source)
+		install = []string{"install", e.Source}
+	}
+	key := cache.GetKeyName(strings.Join(parts, "\n"))
+
+	venv := filepath.Join(key, "venv")
+	python := filepath.Join(venv, "bin", "python")
+	if runtime.GOOS == "windows" {
+		python = filepath.Join(venv, "Scripts", "python.exe")
+	}
+
+	installFn := func() error {
+		slog.Debug("python install", slog.String("requirements", requirements))
+
+		ctx, done := process.WithTimeout(ctx, "build", e.BuildTimeout)
+		cmds := []*exec.Cmd{
+			process.Command(ctx, bin, "-m", "venv", venv),
+			process.Command(ctx, python, append([]string{"-m", "pip"}, install...)...),
+		}
+		env := append(e.Environ[:len(e.Environ):len(e.Environ)], pip().env...)
+		return done(runCmds(ctx, cmds, env, e.Source, e.Stdout, e.Stderr))
+	}
+	if err := cache.Add(key, installFn); err != nil {
+		return "", err
+	}
+	return python, nil
+}
+
+// helper function returns the path to the requirements file.
+// The requirements file is detected if not provided.
+func pythonRequirements(dir, requirements string) string {
+	if requirements != "" {
+		return filepath.Join(dir, requirements)
+	}
+	for _, name := range []string{"requirements.txt", "pyproject.toml"} {
+		if path := filepath.Join(dir, name); exists(path) {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPythonRequirements(t *testing.T) {
+	dir := t.TempDir()
+	assert.Empty(t, pythonRequirements(dir, ""))
+
+	if err := os.WriteFile(filepath.Join(dir, "pyproject.toml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, filepath.Join(dir, "pyproject.toml"), pythonRequirements(dir, ""))
+
+	if err := os.WriteFile(filepath.Join(dir, "requirements.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, filepath.Join(dir, "requirements.txt"), pythonRequirements(dir, ""))
+	assert.Equal(t, filepath.Join(dir, "requirements/prod.txt"), pythonRequirements(dir, "requirements/prod.txt"))
+}
+
+func TestRunPythonExecutable(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 required")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "plugin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plugin", "__init__.py"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plugin", "__main__.py"), []byte("import sys\nprint('hello ' + sys.argv[1])\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("# no requirements\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := new(bytes.Buffer)
+	e := &Execer{
+		Source:  dir,
+		Workdir: t.TempDir(),
+		Environ: append(os.Environ(), "PLUGIN_NAME=world"),
+		Stdout:  stdout,
+		Stderr:  io.Discard,
+	}
+	err := e.runPythonExecutable(context.Background(), &Python{Module: "plugin", Args: []string{"{{ .name }}"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "hello world\n", stdout.String())
+
+	matches, _ := filepath.Glob(filepath.Join(os.Getenv("HOME"), ".cache", "*", "venv"))
+	assert.Len(t, matches, 1, "Want virtual environment created in the cache")
+}
@@ -10,25 +10,46 @@ import (
 
 // spec defines the bitrise plugin.
 type (
+	// AptSource defines an apt repository. The repository
+	// signing key is installed into a dedicated keyring and
+	// the repository is scoped to the keyring using signed-by.
 	AptSource struct {
-		Key  string `yaml:"key,omitempty"`
-		Data string `yaml:"line,omitempty"`
+		Name        string `yaml:"name,omitempty"`        // source file name
+		Key         string `yaml:"key,omitempty"`         // signing key url
+		Data        string `yaml:"line,omitempty"`        // one-line-style source
+		Fingerprint string `yaml:"fingerprint,omitempty"` // expected key fingerprint
+		Format      string `yaml:"format,omitempty"`      // list (default) or deb822
+		Cleanup     bool   `yaml:"cleanup,omitempty"`     // remove after execution
 	}
 
 	Apt struct {
 		Packages []string     `yaml:"packages,omitempty"`
 		Sources  []*AptSource `yaml:"sources,omitempty"`
 	}
-)
 
-type spec struct {
+	// Deps defines the plugin dependencies. Packages may
+	// be pinned to a version using the name=version syntax.
 	Deps struct {
-		Brew  []string
-		Apt   Apt
-		Choco []string
-		Run   []string
+		When   *When
+		Brew   []string
+		Apt    Apt
+		Apk    []string
+		Dnf    []string // installed with dnf or yum
+		Choco  []string
+		Pip    []string
+		Npm    []string
+		Run    []string
+		Strict bool // fail the step if a dependency fails to install
 	}
+
+	// DepsList defines a list of dependency blocks. The
+	// dependencies of every block with matching conditions
+	// are installed.
+	DepsList []*Deps
+
+	// Run defines the plugin entrypoint.
 	Run struct {
+		When   *When
 		Docker struct {
 			Image string
 		}
@@ -40,14 +61,213 @@ type spec struct {
 			Path string
 			Args []string
 		}
-		Go struct {
-			Module string
-		}
-		Binary struct {
-			Source         string
-			FallbackSource string `yaml:"fallback_source,omitempty"`
-		}
+		Go     Go
+		Node   Node
+		Python Python
+		Binary Binary
+	}
+
+	// RunList defines a list of alternative run blocks. The
+	// first block with matching conditions is executed.
+	RunList []*Run
+
+	// Hook defines a pre or post lifecycle hook. The hook
+	// runs based on the step status: always, on-success or
+	// on-failure. Pre hooks run on-success by default, and
+	// post hooks always run by default.
+	Hook struct {
+		Run `yaml:",inline"`
+		On  string `yaml:"on,omitempty"`
+	}
+
+	// HookList defines a list of lifecycle hooks.
+	HookList []*Hook
+
+	// When defines the conditions under which a deps or run
+	// block applies. A condition is satisfied if empty, or
+	// if any of its values match. Env values are matched as
+	// glob patterns against the environment variable value.
+	When struct {
+		OS     StringList        `yaml:"os,omitempty"`
+		Arch   StringList        `yaml:"arch,omitempty"`
+		Distro StringList        `yaml:"distro,omitempty"`
+		Env    map[string]string `yaml:"env,omitempty"`
+	}
+
+	// StringList defines a list of strings that may be
+	// provided as a single string.
+	StringList []string
+
+	// Input defines a plugin input parameter. Inputs are
+	// passed to the plugin as PLUGIN_ prefixed environment
+	// variables.
+	Input struct {
+		Name     string      `yaml:"name"`
+		Type     string      `yaml:"type,omitempty"`
+		Required bool        `yaml:"required,omitempty"`
+		Default  interface{} `yaml:"default,omitempty"`
+		Enum     []string    `yaml:"enum,omitempty"`
+		Secret   bool        `yaml:"secret,omitempty"`
+		Env      string      `yaml:"env,omitempty"` // also export the value as this variable
+	}
+
+	// Go defines a go module that is compiled and executed.
+	// The module is either a path relative to the plugin
+	// source directory, or a fully qualified module path
+	// with version (module@version) that is installed with
+	// go install without cloning.
+	Go struct {
+		Module   string   `yaml:"module,omitempty"`
+		Trimpath bool     `yaml:"trimpath,omitempty"`
+		Ldflags  string   `yaml:"ldflags,omitempty"`
+		Tags     []string `yaml:"tags,omitempty"`
+		Cgo      *bool    `yaml:"cgo,omitempty"` // sets CGO_ENABLED if not empty
+	}
+
+	// Node defines a node.js entrypoint. The package
+	// dependencies are installed with the package manager
+	// and cached by lockfile.
+	Node struct {
+		Entry   string   `yaml:"entry,omitempty"`   // entry file
+		Version string   `yaml:"version,omitempty"` // required node version (e.g. 18 or 18.*)
+		Install string   `yaml:"install,omitempty"` // npm, yarn or pnpm, detected by lockfile if empty
+		Args    []string `yaml:"args,omitempty"`
+	}
+
+	// Python defines a python entrypoint. The requirements
+	// are installed into a virtual environment and cached
+	// by requirements file.
+	Python struct {
+		Entry        string   `yaml:"entry,omitempty"`        // entry script
+		Module       string   `yaml:"module,omitempty"`       // entry module, run with python -m
+		Version      string   `yaml:"version,omitempty"`      // required python version (e.g. 3.11)
+		Requirements string   `yaml:"requirements,omitempty"` // requirements.txt or pyproject.toml, detected if empty
+		Args         []string `yaml:"args,omitempty"`
+	}
+
+	// Binary defines the plugin binary sources. The source
+	// and fallback source are url templates. The platforms
+	// map provides explicit sources by os/arch, where either
+	// the os or arch may be a wildcard (e.g. linux/*).
+	Binary struct {
+		Source         string                   `yaml:"source,omitempty"`
+		FallbackSource string                   `yaml:"fallback_source,omitempty"`
+		Platforms      map[string]*BinarySource `yaml:"platforms,omitempty"`
+	}
+
+	// BinarySource defines a binary source url template and
+	// an optional sha256 checksum of the binary.
+	BinarySource struct {
+		Source   string `yaml:"source"`
+		Checksum string `yaml:"checksum,omitempty"`
+	}
+
+	// Output defines a plugin output. Secret outputs are
+	// written to the secret output file. The name is case
+	// insensitive.
+	Output struct {
+		Name   string `yaml:"name"`
+		Secret bool   `yaml:"secret,omitempty"`
+	}
+)
+
+type spec struct {
+	Inputs  []*Input
+	Outputs []*Output
+	Env     map[string]string
+	Deps    DepsList
+	Pre     HookList
+	Run     RunList
+	Post    HookList
+}
+
+// UnmarshalYAML implements the unmarshal interface.
+func (v *HookList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var out1 []*Hook
+	var out2 *Hook
+
+	if err := unmarshal(&out1); err == nil {
+		*v = out1
+		return nil
+	}
+
+	if err := unmarshal(&out2); err == nil {
+		*v = HookList{out2}
+		return nil
+	}
+	return errors.New("failed to unmarshal hooks")
+}
+
+// UnmarshalYAML implements the unmarshal interface.
+func (v *DepsList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var out1 []*Deps
+	var out2 *Deps
+
+	if err := unmarshal(&out1); err == nil {
+		*v = out1
+		return nil
+	}
+
+	if err := unmarshal(&out2); err == nil {
+		*v = DepsList{out2}
+		return nil
+	}
+	return errors.New("failed to unmarshal deps")
+}
+
+// UnmarshalYAML implements the unmarshal interface.
+func (v *RunList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var out1 []*Run
+	var out2 *Run
+
+	if err := unmarshal(&out1); err == nil {
+		*v = out1
+		return nil
+	}
+
+	if err := unmarshal(&out2); err == nil {
+		*v = RunList{out2}
+		return nil
+	}
+	return errors.New("failed to unmarshal run")
+}
+
+// UnmarshalYAML implements the unmarshal interface.
+func (v *StringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var out1 string
+	var out2 []string
+
+	if err := unmarshal(&out1); err == nil {
+		*v = StringList{out1}
+		return nil
+	}
+
+	if err := unmarshal(&out2); err == nil {
+		*v = out2
+		return nil
+	}
+	return errors.New("failed to unmarshal string list")
+}
+
+// UnmarshalYAML implements the unmarshal interface.
+func (v *BinarySource) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var out1 string
+	var out2 = struct {
+		Source   string `yaml:"source"`
+		Checksum string `yaml:"checksum,omitempty"`
+	}{}
+
+	if err := unmarshal(&out1); err == nil {
+		v.Source = out1
+		return nil
+	}
+
+	if err := unmarshal(&out2); err == nil {
+		v.Source = out2.Source
+		v.Checksum = out2.Checksum
+		return nil
 	}
+	return errors.New("failed to unmarshal binary source")
 }
 
 // UnmarshalYAML implements the unmarshal interface.
@@ -0,0 +1,142 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/drone/plugin/plugin/internal/environ"
+	"golang.org/x/exp/slog"
+)
+
+// platform defines the host platform used to evaluate the
+// deps and run block conditions.
+type platform struct {
+	os     string
+	arch   string
+	distro string
+	env    map[string]string
+}
+
+// String returns the os/arch string representation.
+func (p *platform) String() string {
+	return p.os + "/" + p.arch
+}
+
+// hostPlatform returns the host platform.
+func (e *Execer) hostPlatform() *platform {
+	return &platform{
+		os:     runtime.GOOS,
+		arch:   runtime.GOARCH,
+		distro: distroFunc(),
+		env:    environ.Map(e.Environ),
+	}
+}
+
+// selectDeps returns the dependencies of every block with
+// conditions that match the host platform, merged into a
+// single block.
+func selectDeps(list DepsList, p *platform) *Deps {
+	out := new(Deps)
+	for i, deps := range list {
+		if deps == nil {
+			continue
+		}
+		if ok, reason := deps.When.match(p); !ok {
+			slog.Debug("deps block skipped", slog.Int("index", i), slog.String("reason", reason))
+			continue
+		}
+		slog.Debug("deps block selected", slog.Int("index", i), slog.String("platform", p.String()))
+		out.merge(deps)
+	}
+	return out
+}
+
+// selectRun returns the first run block with conditions
+// that match the host platform.
+func selectRun(list RunList, p *platform) (*Run, error) {
+	if len(list) == 0 {
+		return new(Run), nil
+	}
+	for i, run := range list {
+		if run == nil {
+			continue
+		}
+		if ok, reason := run.When.match(p); !ok {
+			slog.Debug("run block skipped", slog.Int("index", i), slog.String("reason", reason))
+			continue
+		}
+		slog.Debug("run block selected", slog.Int("index", i), slog.String("platform", p.String()))
+		return run, nil
+	}
+	return nil, fmt.Errorf("no run block matches platform %s", p)
+}
+
+// matchTarget returns true if the os and arch conditions
+// match the os and arch. Other conditions are ignored, since
+// they are only known on the host.
+func (w *When) matchTarget(goos, goarch string) bool {
+	return w == nil || (matchAny(w.OS, goos) && matchAny(w.Arch, goarch))
+}
+
+// match returns true if the conditions match the platform.
+// If the conditions do not match, the reason is returned.
+func (w *When) match(p *platform) (bool, string) {
+	if w == nil {
+		return true, ""
+	}
+	if !matchAny(w.OS, p.os) {
+		return false, fmt.Sprintf("os %s not in [%s]", p.os, strings.Join(w.OS, ", "))
+	}
+	if !matchAny(w.Arch, p.arch) {
+		return false, fmt.Sprintf("arch %s not in [%s]", p.arch, strings.Join(w.Arch, ", "))
+	}
+	if !matchAny(w.Distro, p.distro) {
+		return false, fmt.Sprintf("distro %q not in [%s]", p.distro, strings.Join(w.Distro, ", "))
+	}
+	var keys []string
+	for key := range w.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if ok, _ := path.Match(w.Env[key], p.env[key]); !ok {
+			return false, fmt.Sprintf("env %s does not match %q", key, w.Env[key])
+		}
+	}
+	return true, ""
+}
+
+// merge appends the dependencies to the dependency block.
+func (d *Deps) merge(from *Deps) {
+	d.Brew = append(d.Brew, from.Brew...)
+	d.Apt.Packages = append(d.Apt.Packages, from.Apt.Packages...)
+	d.Apt.Sources = append(d.Apt.Sources, from.Apt.Sources...)
+	d.Apk = append(d.Apk, from.Apk...)
+	d.Dnf = append(d.Dnf, from.Dnf...)
+	d.Choco = append(d.Choco, from.Choco...)
+	d.Pip = append(d.Pip, from.Pip...)
+	d.Npm = append(d.Npm, from.Npm...)
+	d.Run = append(d.Run, from.Run...)
+	d.Strict = d.Strict || from.Strict
+}
+
+// helper function returns true if the list is empty or if
+// the value matches any item in the list.
+func matchAny(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
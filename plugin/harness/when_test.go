@@ -0,0 +1,83 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhenMatch(t *testing.T) {
+	host := &platform{
+		os:     "linux",
+		arch:   "arm64",
+		distro: "ubuntu",
+		env:    map[string]string{"PLUGIN_MODE": "fast", "CI": "true"},
+	}
+	tests := []struct {
+		name  string
+		when  *When
+		match bool
+	}{
+		{"nil", nil, true},
+		{"empty", &When{}, true},
+		{"os", &When{OS: StringList{"darwin", "linux"}}, true},
+		{"os mismatch", &When{OS: StringList{"windows"}}, false},
+		{"arch", &When{OS: StringList{"linux"}, Arch: StringList{"arm64"}}, true},
+		{"arch mismatch", &When{Arch: StringList{"amd64"}}, false},
+		{"distro", &When{Distro: StringList{"Ubuntu", "debian"}}, true},
+		{"distro mismatch", &When{Distro: StringList{"alpine"}}, false},
+		{"env", &When{Env: map[string]string{"PLUGIN_MODE": "fast"}}, true},
+		{"env glob", &When{Env: map[string]string{"PLUGIN_MODE": "f*", "CI": "?*"}}, true},
+		{"env mismatch", &When{Env: map[string]string{"PLUGIN_MODE": "slow"}}, false},
+		{"env unset", &When{Env: map[string]string{"PLUGIN_MISSING": "?*"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.when.match(host)
+			assert.Equal(t, tt.match, got)
+			assert.Equal(t, tt.match, reason == "", "Want reason when conditions do not match")
+		})
+	}
+}
+
+func TestSelectDeps(t *testing.T) {
+	host := &platform{os: "linux", arch: "arm64"}
+	list := DepsList{
+		{Apt: Apt{Packages: []string{"curl"}}},
+		{When: &When{Arch: StringList{"arm64"}}, Apt: Apt{Packages: []string{"qemu-user-static"}}, Strict: true},
+		{When: &When{OS: StringList{"darwin"}}, Brew: []string{"coreutils"}},
+	}
+	deps := selectDeps(list, host)
+	assert.Equal(t, []string{"curl", "qemu-user-static"}, deps.Apt.Packages)
+	assert.Empty(t, deps.Brew)
+	assert.True(t, deps.Strict)
+}
+
+func TestSelectRun(t *testing.T) {
+	host := &platform{os: "darwin", arch: "arm64"}
+	linux := &Run{When: &When{OS: StringList{"linux"}}}
+	linux.Bash.Path = "linux.sh"
+	darwin := &Run{When: &When{OS: StringList{"darwin"}}}
+	darwin.Bash.Path = "darwin.sh"
+	fallback := &Run{}
+	fallback.Bash.Path = "run.sh"
+
+	run, err := selectRun(RunList{linux, darwin, fallback}, host)
+	assert.NoError(t, err)
+	assert.Equal(t, "darwin.sh", run.Bash.Path)
+
+	run, err = selectRun(RunList{linux, fallback}, host)
+	assert.NoError(t, err)
+	assert.Equal(t, "run.sh", run.Bash.Path)
+
+	_, err = selectRun(RunList{linux}, host)
+	assert.EqualError(t, err, "no run block matches platform darwin/arm64")
+
+	run, err = selectRun(nil, host)
+	assert.NoError(t, err)
+	assert.NotNil(t, run)
+}
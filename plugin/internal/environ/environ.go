@@ -6,6 +6,7 @@
 package environ
 
 import (
+	"os"
 	"sort"
 	"strings"
 )
@@ -48,3 +49,38 @@ func Map(envs []string) map[string]string {
 	}
 	return m
 }
+
+// WriteFile is a helper function that writes the environment
+// variables to the file in dotenv format. Values are always
+// double quoted, with newlines, quotes and other special
+// characters escaped, so that multi-line values are preserved
+// and values are never reinterpreted (e.g. a leading zero
+// stripped from a number).
+func WriteFile(path string, env map[string]string) error {
+	var keys []string
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(key)
+		sb.WriteString(`="`)
+		sb.WriteString(escaper.Replace(env[key]))
+		sb.WriteString("\"\n")
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// escaper escapes the special characters of double quoted
+// dotenv values.
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\n", `\n`,
+	"\r", `\r`,
+	`"`, `\"`,
+	"$", `\$`,
+	"`", "\\`",
+	"!", `\!`,
+)
@@ -5,9 +5,11 @@
 package environ
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/joho/godotenv"
 )
 
 func TestSlice(t *testing.T) {
@@ -66,3 +68,25 @@ func TestCombine(t *testing.T) {
 		t.Log(diff)
 	}
 }
+
+func TestWriteFile(t *testing.T) {
+	v := map[string]string{
+		"MULTILINE": "line1\nline2\r\nline3",
+		"QUOTES":    `say "hi" it's`,
+		"SPECIAL":   "$HOME ! `tick` \\ \\n",
+		"NUMBER":    "007",
+		"EMPTY":     "",
+	}
+	path := filepath.Join(t.TempDir(), "output.env")
+	if err := WriteFile(path, v); err != nil {
+		t.Fatal(err)
+	}
+	got, err := godotenv.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(v, got); diff != "" {
+		t.Fail()
+		t.Log(diff)
+	}
+}
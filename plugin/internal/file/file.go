@@ -20,6 +20,17 @@ const (
 	defaultDownloadTimeout = 300 * time.Second
 )
 
+// StatusError is returned when the download fails with a
+// non-2xx response status.
+type StatusError struct {
+	Code int
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %d %s", e.Code, http.StatusText(e.Code))
+}
+
 func Download(url string) (string, error) {
 	key := cache.GetKeyName(url)
 	binPath := filepath.Join(key, "step.exe")
@@ -43,6 +54,10 @@ func downloadWithRetries(url, path string) error {
 		if err = download(url, path); err == nil {
 			return nil
 		}
+		// client errors (e.g. 404 not found) are not retried.
+		if status, ok := err.(*StatusError); ok && status.Code < 500 {
+			return err
+		}
 		slog.Error("failed to download url, retrying", slog.String("url", url), "error", err)
 		time.Sleep(1 * time.Second)
 	}
@@ -62,6 +77,12 @@ func download(url, path string) error {
 	}
 	defer resp.Body.Close()
 
+	// the response body is not written if the request
+	// failed, to prevent caching an error page.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Code: resp.StatusCode}
+	}
+
 	f, err := os.Create(path)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("failed to create file at path: %s", path))
@@ -0,0 +1,121 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package process provides helper functions for running
+// plugin processes.
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// waitDelay is the time to wait for the process output
+	// to be closed after the process is killed.
+	waitDelay = 10 * time.Second
+
+	// defaultGracePeriod is the default time to wait for
+	// the process to exit after it is signaled, before the
+	// process is killed.
+	defaultGracePeriod = 10 * time.Second
+)
+
+type graceKey struct{}
+
+// signaler is implemented by context cancellation causes
+// that provide the signal to forward to the process.
+type signaler interface {
+	Signal() os.Signal
+}
+
+// TimeoutError is returned when a plugin phase is terminated
+// because the phase timeout expired.
+type TimeoutError struct {
+	Phase   string
+	Timeout time.Duration
+}
+
+// Error implements the error interface.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Phase, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded so that timeout
+// errors can be detected with errors.Is.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Command returns the command to execute the named program.
+// The process is started in a new process group, where
+// supported. If the context is done before the command
+// completes, the process group is signaled and then killed
+// if it does not exit within the grace period. The signal
+// is taken from the context cancellation cause, if the
+// cause provides a signal, and defaults to SIGTERM.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	grace := GracePeriod(ctx)
+	cmd := exec.CommandContext(ctx, name, args...)
+	setpgid(cmd)
+	cmd.Cancel = func() error {
+		return terminate(cmd, signal(ctx), grace)
+	}
+	cmd.WaitDelay = grace + waitDelay
+	return cmd
+}
+
+// WithGracePeriod returns a child context that configures
+// the time to wait for processes to exit after they are
+// signaled, before they are killed.
+func WithGracePeriod(ctx context.Context, grace time.Duration) context.Context {
+	return context.WithValue(ctx, graceKey{}, grace)
+}
+
+// GracePeriod returns the grace period configured in the
+// context, or the default grace period.
+func GracePeriod(ctx context.Context) time.Duration {
+	if grace, ok := ctx.Value(graceKey{}).(time.Duration); ok {
+		return grace
+	}
+	return defaultGracePeriod
+}
+
+// helper function returns the signal that cancelled the
+// context, or nil if the context was not cancelled by
+// a signal.
+func signal(ctx context.Context) os.Signal {
+	var s signaler
+	if errors.As(context.Cause(ctx), &s) {
+		return s.Signal()
+	}
+	return nil
+}
+
+// WithTimeout returns a child context for the named phase
+// that is cancelled when the timeout expires. A zero timeout
+// does not set a deadline. The returned function must be
+// called with the phase result when the phase completes. It
+// cancels the context and converts the error to a timeout
+// error if the phase timeout expired.
+func WithTimeout(ctx context.Context, phase string, timeout time.Duration) (context.Context, func(error) error) {
+	if timeout <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+	child, cancel := context.WithTimeout(ctx, timeout)
+	return child, func(err error) error {
+		defer cancel()
+		// the error is not converted if the parent deadline
+		// expired, in which case the parent phase reports
+		// the timeout.
+		if err != nil && child.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return &TimeoutError{Phase: phase, Timeout: timeout}
+		}
+		return err
+	}
+}
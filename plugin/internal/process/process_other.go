@@ -0,0 +1,27 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// helper function is a no-op on platforms that do not
+// support process groups.
+func setpgid(cmd *exec.Cmd) {}
+
+// helper function kills the process. Signals cannot be
+// forwarded on platforms that do not support process
+// groups, so the process is killed immediately.
+func terminate(cmd *exec.Cmd, sig os.Signal, grace time.Duration) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
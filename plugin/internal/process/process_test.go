@@ -0,0 +1,152 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCommand_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the child process inherits the output, which would
+	// block until the child exits if only the parent process
+	// was killed.
+	start := time.Now()
+	cmd := Command(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	out, err := cmd.Output()
+	if err == nil {
+		t.Errorf("Want error when timeout expires, got output %q", out)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Want process group killed, waited %s", elapsed)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep command required")
+	}
+
+	ctx, done := WithTimeout(context.Background(), "run", 50*time.Millisecond)
+	err := done(Command(ctx, "sleep", "5").Run())
+
+	timeout := new(TimeoutError)
+	if !errors.As(err, &timeout) {
+		t.Fatalf("Want timeout error, got %v", err)
+	}
+	if got, want := timeout.Phase, "run"; got != want {
+		t.Errorf("Want phase %q, got %q", want, got)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Want timeout error to match context.DeadlineExceeded")
+	}
+	if got, want := err.Error(), "run timed out after 50ms"; got != want {
+		t.Errorf("Want error %q, got %q", want, got)
+	}
+}
+
+func TestWithTimeout_Parent(t *testing.T) {
+	parent, parentDone := WithTimeout(context.Background(), "step", 50*time.Millisecond)
+	ctx, done := WithTimeout(parent, "run", time.Hour)
+	<-ctx.Done()
+
+	// the parent timeout is reported by the parent phase.
+	err := done(ctx.Err())
+	if err != context.DeadlineExceeded {
+		t.Errorf("Want child error unchanged, got %v", err)
+	}
+	err = parentDone(err)
+	timeout := new(TimeoutError)
+	if !errors.As(err, &timeout) || timeout.Phase != "step" {
+		t.Errorf("Want step timeout error, got %v", err)
+	}
+}
+
+func TestWithTimeout_Zero(t *testing.T) {
+	ctx, done := WithTimeout(context.Background(), "run", 0)
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("Want no deadline for zero timeout")
+	}
+	if err := done(nil); err != nil {
+		t.Errorf("Want nil error, got %v", err)
+	}
+}
+
+type signalError struct {
+	sig os.Signal
+}
+
+func (e *signalError) Error() string     { return e.sig.String() }
+func (e *signalError) Signal() os.Signal { return e.sig }
+
+func TestCommand_Signal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix signals required")
+	}
+
+	// the script writes a marker file when it receives the
+	// interrupt signal, to verify the signal is forwarded.
+	marker := filepath.Join(t.TempDir(), "marker")
+	script := "trap 'echo interrupted > " + marker + "; exit 1' INT; sleep 30 & wait"
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cmd := Command(WithGracePeriod(ctx, 5*time.Second), "sh", "-c", script)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel(&signalError{sig: syscall.SIGINT})
+	cmd.Wait()
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("Want interrupt signal forwarded to the process")
+	}
+}
+
+func TestCommand_GracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix signals required")
+	}
+
+	// the script ignores the terminate signal and must be
+	// killed when the grace period expires.
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := Command(WithGracePeriod(ctx, 100*time.Millisecond), "sh", "-c", "trap '' TERM; sleep 30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	cancel()
+	cmd.Wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Want process killed after grace period, waited %s", elapsed)
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	if got, want := GracePeriod(context.Background()), defaultGracePeriod; got != want {
+		t.Errorf("Want default grace period %s, got %s", want, got)
+	}
+	ctx := WithGracePeriod(context.Background(), time.Minute)
+	if got, want := GracePeriod(ctx), time.Minute; got != want {
+		t.Errorf("Want grace period %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// helper function configures the command to start in a new
+// process group, so that child processes can be terminated
+// together with the parent process.
+func setpgid(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// helper function sends the signal to the process group and
+// kills the process group if it has not exited when the
+// grace period expires.
+func terminate(cmd *exec.Cmd, sig os.Signal, grace time.Duration) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	pgid := -cmd.Process.Pid
+	if grace <= 0 {
+		return syscall.Kill(pgid, syscall.SIGKILL)
+	}
+
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		s = syscall.SIGTERM
+	}
+	if err := syscall.Kill(pgid, s); err != nil {
+		return err
+	}
+
+	// the process group is killed when the grace period
+	// expires. the kill fails with ESRCH, which is ignored,
+	// if every process in the group already exited.
+	time.AfterFunc(grace, func() {
+		syscall.Kill(pgid, syscall.SIGKILL)
+	})
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package versions provides helper functions for resolving
+// semantic version ranges.
+package versions
+
+import (
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Latest is the version range that resolves to the latest
+// stable version.
+const Latest = "latest"
+
+// Resolve returns the highest version that satisfies the
+// version range. An exact match always takes precedence. The
+// range may be latest, a caret (^3) or tilde (~3.1) range, a
+// wildcard (3.x) or a partial version (3 or 3.1), which
+// matches the latest version with the same prefix. Versions
+// that are not valid semantic versions are ignored, and
+// pre-release versions are only matched by exact version.
+func Resolve(spec string, versions []string) (string, bool) {
+	spec = strings.TrimSpace(spec)
+	for _, v := range versions {
+		if v == spec {
+			return v, true
+		}
+	}
+
+	var constraint *semver.Constraints
+	var err error
+	switch {
+	case spec == Latest:
+		constraint, err = semver.NewConstraint("*")
+	case isPartial(spec):
+		constraint, err = semver.NewConstraint(spec + ".x")
+	default:
+		constraint, err = semver.NewConstraint(spec)
+	}
+	if err != nil {
+		return "", false
+	}
+
+	var best *semver.Version
+	var match string
+	for _, v := range versions {
+		version, err := semver.NewVersion(v)
+		if err != nil || !constraint.Check(version) {
+			continue
+		}
+		if best == nil || version.GreaterThan(best) {
+			best, match = version, v
+		}
+	}
+	return match, best != nil
+}
+
+// helper function returns true if the version is a partial
+// version with a major, and optional minor, component (e.g.
+// 3, v3 or 3.1).
+func isPartial(spec string) bool {
+	parts := strings.Split(strings.TrimPrefix(spec, "v"), ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 Harness Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package versions
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	available := []string{"2.9.0", "3.0.0", "3.1.0", "3.1.2", "3.2.0", "3.3.0-beta.1", "4.0.0", "main"}
+	tests := []struct {
+		spec     string
+		expected string
+		ok       bool
+	}{
+		{"3.1.2", "3.1.2", true},
+		{"main", "main", true},
+		{"3.3.0-beta.1", "3.3.0-beta.1", true},
+		{"latest", "4.0.0", true},
+		{"^3", "3.2.0", true},
+		{"^3.1", "3.2.0", true},
+		{"~3.1", "3.1.2", true},
+		{"~3.1.0", "3.1.2", true},
+		{"3.x", "3.2.0", true},
+		{"3.1.x", "3.1.2", true},
+		{"3", "3.2.0", true},
+		{"v3", "3.2.0", true},
+		{"3.1", "3.1.2", true},
+		{"2", "2.9.0", true},
+		{"^5", "", false},
+		{"3.1.5", "", false},
+		{"invalid", "", false},
+	}
+	for _, test := range tests {
+		got, ok := Resolve(test.spec, available)
+		if ok != test.ok {
+			t.Errorf("Want version %s resolved %v, got %v", test.spec, test.ok, ok)
+		}
+		if got != test.expected {
+			t.Errorf("Want version %s resolved to %q, got %q", test.spec, test.expected, got)
+		}
+	}
+}
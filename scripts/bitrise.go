@@ -11,10 +11,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
-	"github.com/coreos/go-semver/semver"
+	"github.com/Masterminds/semver"
 	"gopkg.in/yaml.v2"
 )
 
@@ -87,7 +88,7 @@ func main() {
 
 	// determine the latest / default revision
 	for _, plugin_ := range plugins {
-		var versions semver.Versions
+		var versions semver.Collection
 		for _, release_ := range plugin_.Releases {
 			// parse the semver and append to the list
 			version, err := semver.NewVersion(release_.Version)
@@ -97,9 +98,9 @@ func main() {
 			versions = append(versions, version)
 		}
 		// sort the semver
-		semver.Sort(versions)
+		sort.Sort(versions)
 		// extract the latest version
-		plugin_.Version = versions[len(versions)-1].String()
+		plugin_.Version = versions[len(versions)-1].Original()
 	}
 
 	// generate the file from a template
@@ -0,0 +1,150 @@
+//go:build ignore
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// this script clones a registry of Harness plugin descriptors and
+// generates a static lookup table in Go code. The static lookup table
+// can be used to lookup a named Harness plugin (e.g webhook) and
+// determine the underlying repository and commit sha.
+//
+// The registry is a git repository, or a local directory, with a
+// descriptor file for each plugin at plugins/<name>.yml:
+//
+//	name: webhook
+//	repo: https://github.com/drone-plugins/drone-webhook.git
+//	versions:
+//	  1.0.0: b83c0042154f9c5d4bc3c42a847c3c287c12a505
+func main() {
+	out := flag.String("out", "lookup_gen.go", "output file")
+	registry := flag.String("registry", "", "registry git repository url or local directory")
+	flag.Parse()
+
+	if *registry == "" {
+		log.Fatalln("registry is required")
+	}
+
+	codedir := *registry
+	if _, err := os.Stat(codedir); err != nil {
+		// temporary directory to clone the registry
+		codedir, err = ioutil.TempDir("", "")
+		defer os.RemoveAll(codedir)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		// clone the registry
+		cmd := exec.Command("git", "clone", "--depth=1", *registry, codedir)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	// find all plugin descriptor files
+	var matches []string
+	for _, ext := range []string{"*.yml", "*.yaml", "*.json"} {
+		found, _ := filepath.Glob(filepath.Join(codedir, "plugins", ext))
+		matches = append(matches, found...)
+	}
+
+	// collate plugin data
+	plugins := map[string]*plugin{}
+	for _, match := range matches {
+		// read the file
+		raw, err := ioutil.ReadFile(match)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// parse the yaml
+		plugin_ := new(plugin)
+		if err := yaml.Unmarshal(raw, plugin_); err != nil {
+			log.Fatal(err)
+		}
+		if plugin_.Name == "" || plugin_.Repo == "" {
+			log.Fatalf("%s: name and repo are required", match)
+		}
+		if _, ok := plugins[plugin_.Name]; ok {
+			log.Fatalf("%s: duplicate plugin %s", match, plugin_.Name)
+		}
+		plugins[plugin_.Name] = plugin_
+	}
+
+	// generate the file from a template
+	t, err := template.New("_").Funcs(template.FuncMap{"sorted": sorted}).Parse(tmpl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, plugins); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "-" {
+		// write to stdout
+		io.Copy(os.Stdout, bytes.NewReader(src))
+	} else {
+		// write to a file
+		if err := ioutil.WriteFile(*out, src, 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+type plugin struct {
+	Name     string            `yaml:"name"`
+	Repo     string            `yaml:"repo"`
+	Versions map[string]string `yaml:"versions"`
+}
+
+// helper function returns the sorted map keys.
+func sorted(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+const tmpl = `// Code generated by scripts/harness.go; DO NOT EDIT.
+
+package harness
+
+var index = map[string]plugin{
+	{{- range . }}
+	{{ printf "%q" .Name }}: {
+		name: {{ printf "%q" .Name }},
+		repo: {{ printf "%q" .Repo }},
+		versions: map[string]string{
+			{{- $versions := .Versions }}
+			{{- range sorted .Versions }}
+			{{ printf "%q" . }}: {{ printf "%q" (index $versions .) }},
+			{{- end }}
+		},
+	},
+	{{- end }}
+}
+`